@@ -1,26 +1,71 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+const (
+	SlowSubscriberDisconnect = "disconnect"
+	SlowSubscriberResync     = "resync"
+)
+
 type Config struct {
 	// Core
 	URL      string `mapstructure:"url"`
 	Upstream string `mapstructure:"upstream"`
 	Token    string `mapstructure:"token"`
+	// Remotes is a JSON array of remotes with their own upstream, see
+	// RemoteConfigs. It takes precedence over Token.
+	Remotes string `mapstructure:"remotes"`
 
 	// Network / Routing
 	BasePath string `mapstructure:"base_path"`
 
+	// Upstream connection, shared by the client and the proxied endpoints.
+	UpstreamCACert             string        `mapstructure:"upstream_ca_cert"`
+	UpstreamClientCert         string        `mapstructure:"upstream_client_cert"`
+	UpstreamClientKey          string        `mapstructure:"upstream_client_key"`
+	UpstreamInsecureSkipVerify bool          `mapstructure:"upstream_insecure_skip_verify"`
+	UpstreamProxy              string        `mapstructure:"upstream_proxy"`
+	UpstreamTimeout            time.Duration `mapstructure:"upstream_timeout"`
+	UpstreamConnectTimeout     time.Duration `mapstructure:"upstream_connect_timeout"`
+	// UpstreamHeaders is a JSON object with the extra request headers of
+	// every remote under "*"; see UpstreamHeaderSet.
+	UpstreamHeaders string `mapstructure:"upstream_headers"`
+
+	// Offline is a comma-separated list of feature file fixtures, or
+	// directories of them, served instead of an upstream; see OfflineFixtures.
+	Offline      string `mapstructure:"offline"`
+	OfflineWatch bool   `mapstructure:"offline_watch"`
+
+	// Admin API
+	AdminToken  string        `mapstructure:"admin_token"`
+	AdminURL    string        `mapstructure:"admin_url"`
+	AdminReload time.Duration `mapstructure:"admin_reload"`
+
 	// Server
 	ListenAddress string `mapstructure:"listen_address"`
 	Reload        string `mapstructure:"reload"`
+	// StaleAfter is how long an environment may go without syncing with its
+	// upstream before it is reported as stale; 0 disables it.
+	StaleAfter time.Duration `mapstructure:"stale_after"`
 
 	Backup bool `mapstructure:"backup"`
 
@@ -36,9 +81,20 @@ type Config struct {
 	Streamer       bool `mapstructure:"streamer"`
 	EnableFrontend bool `mapstructure:"enable_frontend_api"`
 	Delta          bool `mapstructure:"delta"`
+	DeltaPolling   bool `mapstructure:"delta_polling"`
 	EnvFromToken   bool `mapstructure:"env_from_token"`
 	Webhook        bool `mapstructure:"webhook"`
 
+	RequestOverrides bool `mapstructure:"request_overrides"`
+
+	// StreamSlowSubscriber is what happens to a streaming client that cannot
+	// keep up: "disconnect" or "resync".
+	StreamSlowSubscriber string `mapstructure:"stream_slow_subscriber"`
+
+	// Frontend API
+	FrontendDefaultContext string `mapstructure:"frontend_default_context"`
+	TrustedProxies         string `mapstructure:"trusted_proxies"`
+
 	// Storage
 	Storage string `mapstructure:"storage"`
 
@@ -58,11 +114,26 @@ func InitConfig() (*Config, error) {
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
 	pflag.String("url", "", "DEPRECATED: Unleash URL (e.g. https://unleash.my-site.com) without /api. Use --upstream instead.")
-	pflag.String("upstream", "", "Unleash upstream URL to load feature flags (e.g. https://unleash.my-site.com) without /api, can be an Unleash instance or Unleash Edge.")
+	pflag.String("upstream", "", "Unleash upstream URL to load feature flags (e.g. https://unleash.my-site.com) without /api, can be an Unleash instance or Unleash Edge. A comma-separated list is tried in order, failing over to the next URL.")
 	pflag.String("token", "", "Comma-separated Unleash client token(s) to fetch feature flag configurations.")
+	pflag.String("remotes", "", "JSON array of remotes, each with a token and optionally its own upstream, delta and deltaPolling mode (e.g. '[{\"token\": \"*:production.abc\", \"upstream\": \"https://unleash.prod\", \"delta\": true}]'). Takes precedence over --token.")
 	pflag.String("base_path", "", "Base URL path if running behind an ingress with a prefix (e.g. /overleash).")
+	pflag.String("upstream_ca_cert", "", "Path to a PEM bundle of CA certificates trusted for the upstream, in addition to the system ones.")
+	pflag.String("upstream_client_cert", "", "Path to a PEM client certificate presented to the upstream (mTLS). Requires --upstream_client_key.")
+	pflag.String("upstream_client_key", "", "Path to the PEM private key of --upstream_client_cert.")
+	pflag.Bool("upstream_insecure_skip_verify", false, "Skip verifying the upstream's TLS certificate. Only for development.")
+	pflag.String("upstream_proxy", "", "HTTP(S) proxy URL used to reach the upstream. Defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.")
+	pflag.Duration("upstream_timeout", 10*time.Second, "Timeout of a request to the upstream, excluding streaming (0 disables it).")
+	pflag.Duration("upstream_connect_timeout", 5*time.Second, "Timeout for connecting to the upstream, including the TLS handshake (0 disables it).")
+	pflag.String("upstream_headers", "", "JSON object of extra headers sent to the upstream of every remote under \"*\" (e.g. '{\"*\": {\"X-Service-Token\": \"env:SERVICE_TOKEN\"}}'). Values prefixed with env: or file: are read from that environment variable or file. Headers of one remote go in its \"headers\" in --remotes.")
+	pflag.String("offline", "", "Comma-separated feature file fixtures (JSON or YAML), or directories of them, to serve instead of an upstream. The file name is the environment, e.g. development.yaml.")
+	pflag.Bool("offline_watch", false, "Whether to reload the offline fixtures when they change.")
+	pflag.String("admin_token", "", "Unleash admin API token. When set, tags, links, owners, environments and lifecycle stage of the flags are loaded from the admin API.")
+	pflag.String("admin_url", "", "Unleash URL of the admin API without /api. Defaults to the upstream of each remote.")
+	pflag.Duration("admin_reload", 5*time.Minute, "How often flag metadata is reloaded from the admin API.")
 	pflag.String("listen_address", ":5433", "Address to listen on for incoming connections. Can be just a port (e.g. ':5433'), an IP with port (e.g. '127.0.0.1:5433'), or '0.0.0.0:5433' to listen on all interfaces.")
 	pflag.String("reload", "0", "Reload frequency in minutes for refreshing feature flag configuration (0 disables automatic reloading).")
+	pflag.Duration("stale_after", 0, "Mark an environment as stale, and /health as degraded, when it has not synced with its upstream for this long (0 disables it).")
 	pflag.Bool("verbose", false, "Enable verbose logging to troubleshoot and diagnose issues.")
 	pflag.Bool("register_metrics", false, "Register metrics")
 	pflag.Bool("register", false, "Whether to register itself to the connected Unleash server.")
@@ -70,11 +141,17 @@ func InitConfig() (*Config, error) {
 	pflag.Bool("streamer", false, "Whether this instance streams the delta events.")
 	pflag.Bool("enable_frontend_api", true, "Whether to enable the frontend API.")
 	pflag.Bool("delta", false, "Whether to to use the upstream delta streaming API.")
+	pflag.Bool("delta_polling", false, "Whether to poll the upstream delta API (/api/client/delta) for incremental updates instead of fetching all flags, e.g. where long-lived connections are dropped.")
 	pflag.Bool("env_from_token", false, "Whether to resolve the environment from the client token in the Authorization header instead of using the configured environment.")
 	pflag.Bool("prometheus_metrics", false, "Whether to collect prometheus metrics from the server.")
 	pflag.Int("prometheus_metrics_port", 9100, "Which port to expose Prometheus metrics.")
 	pflag.Bool("webhook", false, "Whether to expose webhook that will refresh the flags.")
 	pflag.Bool("backup", true, "Whether backup feature file in storage.")
+	pflag.String("stream_slow_subscriber", SlowSubscriberResync, "What to do with a streaming client that cannot keep up: 'disconnect' it so it reconnects, or 'resync' it with a fresh hydration.")
+	pflag.Bool("request_overrides", false, "Whether to apply transient overrides from the X-Overleash-Override header on the client and frontend APIs.")
+
+	pflag.String("frontend_default_context", "", "JSON object of default frontend API context values, keyed by environment, frontend token or \"*\" (e.g. '{\"development\": {\"appName\": \"web\"}}').")
+	pflag.String("trusted_proxies", "", "Comma-separated IPs or CIDR ranges of proxies whose X-Forwarded-For header is trusted for the remoteAddress.")
 
 	pflag.String("storage", "file", "Storage backend: file or redis")
 
@@ -96,6 +173,42 @@ func InitConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.StreamSlowSubscriber != SlowSubscriberDisconnect && cfg.StreamSlowSubscriber != SlowSubscriberResync {
+		return nil, fmt.Errorf("invalid stream_slow_subscriber %q, expected %q or %q", cfg.StreamSlowSubscriber, SlowSubscriberDisconnect, SlowSubscriberResync)
+	}
+
+	if _, err := cfg.RemoteConfigs(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.OfflineFixtures(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.DefaultContexts(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.TrustedProxyPrefixes(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.UpstreamTLSConfig(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.UpstreamProxyURL(); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.UpstreamHeaderSet(); err != nil {
+		return nil, err
+	}
+
+	if cfg.UpstreamInsecureSkipVerify {
+		log.Warn("TLS verification of the upstream is disabled, do not use this in production")
+	}
+
 	return &cfg, nil
 }
 
@@ -117,6 +230,197 @@ func (c *Config) Tokens() []string {
 	return strings.Split(c.Token, ",")
 }
 
+// Remote is an Unleash environment Overleash loads flags from.
+type Remote struct {
+	Token string
+	// Upstreams are tried in order; the first is preferred.
+	Upstreams []string
+	// Delta streams updates from the upstream, DeltaPolling polls the delta
+	// API; without either all flags are polled.
+	Delta        bool
+	DeltaPolling bool
+	// Headers are sent with every request to the upstreams of the remote,
+	// on top of and winning over UpstreamHeaderSet.
+	Headers map[string]string
+}
+
+// RemoteConfigs returns the remotes to load flags from. Without Remotes there
+// is one remote per token. The upstreams and sync mode of a remote default to
+// the global ones; enabling one delta mode on a remote disables the other.
+// Upstreams are comma-separated URLs in order of preference.
+func (c *Config) RemoteConfigs() ([]Remote, error) {
+	upstream := c.Upstream
+	if upstream == "" {
+		upstream = c.URL
+	}
+
+	if strings.TrimSpace(c.Remotes) == "" {
+		tokens := c.Tokens()
+		remotes := make([]Remote, len(tokens))
+
+		if c.Delta && c.DeltaPolling {
+			return nil, errors.New("invalid remotes: delta and delta_polling cannot both be enabled")
+		}
+
+		for i, token := range tokens {
+			remotes[i] = Remote{Token: token, Upstreams: splitUpstreams(upstream), Delta: c.Delta, DeltaPolling: c.DeltaPolling}
+		}
+
+		return remotes, nil
+	}
+
+	var entries []struct {
+		Token        string            `json:"token"`
+		Upstream     string            `json:"upstream"`
+		Delta        *bool             `json:"delta"`
+		DeltaPolling *bool             `json:"deltaPolling"`
+		Headers      map[string]string `json:"headers"`
+	}
+
+	if err := json.Unmarshal([]byte(c.Remotes), &entries); err != nil {
+		return nil, fmt.Errorf("invalid remotes: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("invalid remotes: at least one remote is required")
+	}
+
+	remotes := make([]Remote, len(entries))
+
+	for i, entry := range entries {
+		if entry.Token == "" {
+			return nil, fmt.Errorf("invalid remotes: remote %d has no token", i)
+		}
+
+		headers, err := headerValues(entry.Headers)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid remotes: remote %d: %w", i, err)
+		}
+
+		remotes[i] = Remote{Token: entry.Token, Upstreams: splitUpstreams(entry.Upstream), Delta: c.Delta, DeltaPolling: c.DeltaPolling, Headers: headers}
+
+		if entry.Upstream == "" {
+			remotes[i].Upstreams = splitUpstreams(upstream)
+		}
+
+		if entry.Delta != nil {
+			remotes[i].Delta = *entry.Delta
+
+			if *entry.Delta && entry.DeltaPolling == nil {
+				remotes[i].DeltaPolling = false
+			}
+		}
+
+		if entry.DeltaPolling != nil {
+			remotes[i].DeltaPolling = *entry.DeltaPolling
+
+			if *entry.DeltaPolling && entry.Delta == nil {
+				remotes[i].Delta = false
+			}
+		}
+
+		if remotes[i].Delta && remotes[i].DeltaPolling {
+			return nil, fmt.Errorf("invalid remotes: remote %d cannot both stream and poll the delta API", i)
+		}
+	}
+
+	return remotes, nil
+}
+
+func splitUpstreams(upstream string) []string {
+	upstreams := make([]string, 0)
+
+	for _, u := range strings.Split(upstream, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			upstreams = append(upstreams, u)
+		}
+	}
+
+	// Keep one, possibly empty, upstream so a remote always has one.
+	if len(upstreams) == 0 {
+		upstreams = append(upstreams, "")
+	}
+
+	return upstreams
+}
+
+// Fixture is a feature file served in offline mode.
+type Fixture struct {
+	Environment string
+	Path        string
+}
+
+// OfflineFixtures returns the fixtures of Offline, nil when it is not set.
+// Directories are expanded to the JSON and YAML files in them. The environment
+// of a fixture is its file name without extension.
+func (c *Config) OfflineFixtures() ([]Fixture, error) {
+	if strings.TrimSpace(c.Offline) == "" {
+		return nil, nil
+	}
+
+	fixtures := make([]Fixture, 0)
+
+	for _, path := range strings.Split(c.Offline, ",") {
+		path = strings.TrimSpace(path)
+
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid offline fixture: %w", err)
+		}
+
+		paths := []string{path}
+
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid offline fixture: %w", err)
+			}
+
+			paths = paths[:0]
+
+			for _, entry := range entries {
+				if !entry.IsDir() && isFixture(entry.Name()) {
+					paths = append(paths, filepath.Join(path, entry.Name()))
+				}
+			}
+		} else if !isFixture(path) {
+			return nil, fmt.Errorf("invalid offline fixture %s: expected a .json, .yaml or .yml file", path)
+		}
+
+		for _, p := range paths {
+			env := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+
+			if slices.ContainsFunc(fixtures, func(f Fixture) bool { return f.Environment == env }) {
+				return nil, fmt.Errorf("invalid offline fixture %s: environment %s has more than one fixture", p, env)
+			}
+
+			fixtures = append(fixtures, Fixture{Environment: env, Path: p})
+		}
+	}
+
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("invalid offline fixtures %q: no fixtures found", c.Offline)
+	}
+
+	return fixtures, nil
+}
+
+func isFixture(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+
+	return false
+}
+
 // CleanBasePath ensures the path starts with / and does not end with /
 // This makes it safe for http.StripPrefix
 func (c *Config) CleanBasePath() string {
@@ -136,3 +440,201 @@ func (c *Config) CleanBasePath() string {
 	// Remove trailing slash
 	return strings.TrimSuffix(path, "/")
 }
+
+// DefaultContext holds frontend API context values used when the client does
+// not send them.
+type DefaultContext struct {
+	UserId        *string           `json:"userId"`
+	SessionId     *string           `json:"sessionId"`
+	Environment   *string           `json:"environment"`
+	AppName       *string           `json:"appName"`
+	CurrentTime   *string           `json:"currentTime"`
+	RemoteAddress *string           `json:"remoteAddress"`
+	Properties    map[string]string `json:"properties"`
+}
+
+// DefaultContexts parses FrontendDefaultContext. Keys are an environment name,
+// a frontend token, or "*" for every request.
+func (c *Config) DefaultContexts() (map[string]DefaultContext, error) {
+	contexts := make(map[string]DefaultContext)
+
+	if strings.TrimSpace(c.FrontendDefaultContext) == "" {
+		return contexts, nil
+	}
+
+	if err := json.Unmarshal([]byte(c.FrontendDefaultContext), &contexts); err != nil {
+		return nil, fmt.Errorf("invalid frontend_default_context: %w", err)
+	}
+
+	return contexts, nil
+}
+
+// TrustedProxyPrefixes parses TrustedProxies. A plain IP is trusted on its own.
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+
+	for _, entry := range strings.Split(c.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// UpstreamTLSConfig builds the TLS configuration for connections to the
+// upstream from the CA bundle, client certificate and verification settings.
+func (c *Config) UpstreamTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.UpstreamInsecureSkipVerify,
+	}
+
+	if c.UpstreamCACert != "" {
+		pem, err := os.ReadFile(c.UpstreamCACert)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream_ca_cert: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid upstream_ca_cert: no certificates found in %s", c.UpstreamCACert)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.UpstreamClientCert == "") != (c.UpstreamClientKey == "") {
+		return nil, errors.New("upstream_client_cert and upstream_client_key must be set together")
+	}
+
+	if c.UpstreamClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.UpstreamClientCert, c.UpstreamClientKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// UpstreamProxyURL parses UpstreamProxy. It returns nil when no proxy is
+// configured, in which case the proxy environment variables apply.
+func (c *Config) UpstreamProxyURL() (*url.URL, error) {
+	if strings.TrimSpace(c.UpstreamProxy) == "" {
+		return nil, nil
+	}
+
+	proxyUrl, err := url.Parse(strings.TrimSpace(c.UpstreamProxy))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream_proxy: %w", err)
+	}
+
+	if proxyUrl.Scheme != "http" && proxyUrl.Scheme != "https" && proxyUrl.Scheme != "socks5" {
+		return nil, fmt.Errorf("invalid upstream_proxy %q: scheme must be http, https or socks5", c.UpstreamProxy)
+	}
+
+	return proxyUrl, nil
+}
+
+// UpstreamHeaderSet parses UpstreamHeaders into the headers sent to the
+// upstream of every remote, which are under "*". Headers of a single remote
+// are set with the headers of its entry in Remotes.
+func (c *Config) UpstreamHeaderSet() (map[string]string, error) {
+	if strings.TrimSpace(c.UpstreamHeaders) == "" {
+		return map[string]string{}, nil
+	}
+
+	var sets map[string]map[string]string
+
+	if err := json.Unmarshal([]byte(c.UpstreamHeaders), &sets); err != nil {
+		return nil, fmt.Errorf("invalid upstream_headers: %w", err)
+	}
+
+	for key := range sets {
+		if key != "*" {
+			return nil, fmt.Errorf("invalid upstream_headers: unsupported key %q, set the headers of a remote with \"headers\" in remotes", key)
+		}
+	}
+
+	headers, err := headerValues(sets["*"])
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream_headers: %w", err)
+	}
+
+	return headers, nil
+}
+
+// headerValues resolves the values of headers. A value "env:NAME" is read
+// from the environment variable NAME and "file:PATH" from the file at PATH;
+// anything else is used as is.
+func headerValues(headers map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(headers))
+
+	for name, value := range headers {
+		v, err := headerValue(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+
+		resolved[name] = v
+	}
+
+	return resolved, nil
+}
+
+func headerValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		resolved, ok := os.LookupEnv(name)
+
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return resolved, nil
+	case strings.HasPrefix(value, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,108 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/charmbracelet/log"
+)
+
+// NewTransportFromConfig builds the transport used for every connection to the
+// upstream, with the TLS, proxy and connect timeout settings of cfg.
+func NewTransportFromConfig(cfg *config.Config) *http.Transport {
+	tlsConfig, err := cfg.UpstreamTLSConfig()
+
+	if err != nil {
+		log.Fatalf("invalid upstream TLS configuration: %v", err)
+	}
+
+	proxyUrl, err := cfg.UpstreamProxyURL()
+
+	if err != nil {
+		log.Fatalf("invalid upstream proxy: %v", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DialContext = (&net.Dialer{
+		Timeout:   cfg.UpstreamConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = cfg.UpstreamConnectTimeout
+
+	if proxyUrl != nil {
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	return transport
+}
+
+// New returns a client on transport that does not follow redirects. A zero
+// timeout means none, which long-lived streams need.
+func New(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// HeadersFromConfig returns the extra headers cfg sends to the upstream of
+// every remote.
+func HeadersFromConfig(cfg *config.Config) http.Header {
+	set, err := cfg.UpstreamHeaderSet()
+
+	if err != nil {
+		log.Fatalf("invalid upstream headers: %v", err)
+	}
+
+	return NewHeader(set)
+}
+
+// NewHeader returns the header with the values of set.
+func NewHeader(set map[string]string) http.Header {
+	header := make(http.Header, len(set))
+
+	for name, value := range set {
+		header.Set(name, value)
+	}
+
+	return header
+}
+
+type headerTransport struct {
+	next   http.RoundTripper
+	header http.Header
+}
+
+// WithHeaders returns a transport that adds headers to every request before
+// passing it to next. A header of a later set wins over an earlier one.
+func WithHeaders(next http.RoundTripper, headers ...http.Header) http.RoundTripper {
+	merged := make(http.Header)
+
+	for _, header := range headers {
+		for name, values := range header {
+			merged[name] = values
+		}
+	}
+
+	if len(merged) == 0 {
+		return next
+	}
+
+	return &headerTransport{next: next, header: merged}
+}
+
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+
+	for name, values := range t.header {
+		r.Header[name] = values
+	}
+
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,269 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/config"
+)
+
+func writePem(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// newCertificate creates a certificate signed by parent, or a self-signed CA
+// when parent is nil.
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "overleash"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func get(cfg *config.Config, url string) error {
+	res, err := New(NewTransportFromConfig(cfg), 5*time.Second).Get(url)
+
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func TestTransportTrustsConfiguredCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	if err := get(&config.Config{}, ts.URL); err == nil {
+		t.Fatal("Expected an unknown CA to be rejected")
+	}
+
+	cfg := &config.Config{UpstreamCACert: writePem(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)}
+
+	if err := get(cfg, ts.URL); err != nil {
+		t.Errorf("Expected the configured CA to be trusted, got %v", err)
+	}
+
+	if err := get(&config.Config{UpstreamInsecureSkipVerify: true}, ts.URL); err != nil {
+		t.Errorf("Expected verification to be skipped, got %v", err)
+	}
+}
+
+func TestTransportPresentsClientCertificate(t *testing.T) {
+	ca, caKey := newCertificate(t, nil, nil)
+	cert, key := newCertificate(t, ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	ts.StartTLS()
+	defer ts.Close()
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		UpstreamCACert: writePem(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw),
+	}
+
+	if err := get(cfg, ts.URL); err == nil {
+		t.Fatal("Expected the upstream to require a client certificate")
+	}
+
+	cfg.UpstreamClientCert = writePem(t, "client.pem", "CERTIFICATE", cert.Raw)
+	cfg.UpstreamClientKey = writePem(t, "client-key.pem", "EC PRIVATE KEY", keyDer)
+
+	if err := get(cfg, ts.URL); err != nil {
+		t.Errorf("Expected the client certificate to be accepted, got %v", err)
+	}
+}
+
+func TestTransportUsesConfiguredProxy(t *testing.T) {
+	hosts := make(chan string, 1)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.URL.Host
+	}))
+	defer proxy.Close()
+
+	if err := get(&config.Config{UpstreamProxy: proxy.URL}, "http://unleash.internal/api/client/features"); err != nil {
+		t.Fatalf("Expected the request to go through the proxy, got %v", err)
+	}
+
+	if host := <-hosts; host != "unleash.internal" {
+		t.Errorf("Expected the proxy to receive the request for unleash.internal, got %q", host)
+	}
+}
+
+func TestUpstreamConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "missing CA file", cfg: config.Config{UpstreamCACert: "/does/not/exist.pem"}},
+		{name: "certificate without key", cfg: config.Config{UpstreamClientCert: "client.pem"}},
+		{name: "key without certificate", cfg: config.Config{UpstreamClientKey: "client-key.pem"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.UpstreamTLSConfig(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := (&config.Config{UpstreamProxy: "ftp://proxy"}).UpstreamProxyURL(); err == nil {
+		t.Error("Expected an unsupported proxy scheme to be rejected")
+	}
+}
+
+func TestWithHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer ts.Close()
+
+	shared := http.Header{"X-Service-Token": {"shared"}, "X-Tenant": {"all"}}
+
+	tests := []struct {
+		name       string
+		remote     http.Header
+		wantTenant string
+	}{
+		{name: "shared headers", remote: nil, wantTenant: "all"},
+		{name: "remote headers win", remote: http.Header{"X-Tenant": {"prod"}}, wantTenant: "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(WithHeaders(http.DefaultTransport, shared, tt.remote), 5*time.Second)
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			header := <-received
+
+			if header.Get("X-Service-Token") != "shared" || header.Get("X-Tenant") != tt.wantTenant {
+				t.Errorf("Expected the shared token and tenant %s, got %v", tt.wantTenant, header)
+			}
+			if req.Header.Get("X-Tenant") != "" {
+				t.Error("Expected the original request to be left untouched")
+			}
+		})
+	}
+}
+
+func TestUpstreamHeaderSet(t *testing.T) {
+	t.Setenv("OVERLEASH_TEST_SERVICE_TOKEN", "from-env")
+
+	cfg := &config.Config{
+		UpstreamHeaders: `{"*": {"X-Service-Token": "env:OVERLEASH_TEST_SERVICE_TOKEN", "X-Static": "value"}}`,
+	}
+
+	set, err := cfg.UpstreamHeaderSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if set["X-Service-Token"] != "from-env" || set["X-Static"] != "value" {
+		t.Errorf("Expected the values to be resolved, got %v", set)
+	}
+
+	cfg.UpstreamHeaders = `{"*": {"X-Service-Token": "env:OVERLEASH_TEST_MISSING"}}`
+
+	if _, err := cfg.UpstreamHeaderSet(); err == nil {
+		t.Error("Expected a missing environment variable to be an error")
+	}
+
+	cfg.UpstreamHeaders = `{"production": {"X-Tenant": "prod"}}`
+
+	if _, err := cfg.UpstreamHeaderSet(); err == nil {
+		t.Error("Expected headers keyed by environment to be rejected")
+	}
+}
+
+func TestRemoteHeaders(t *testing.T) {
+	tenant := filepath.Join(t.TempDir(), "tenant")
+	if err := os.WriteFile(tenant, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Remotes: `[{"token": "*:production.abc", "upstream": "https://eu.example.com", "headers": {"X-Tenant": "file:` + tenant + `"}}, {"token": "*:production.def", "upstream": "https://us.example.com", "headers": {"X-Tenant": "us"}}]`,
+	}
+
+	remotes, err := cfg.RemoteConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if remotes[0].Headers["X-Tenant"] != "from-file" || remotes[1].Headers["X-Tenant"] != "us" {
+		t.Errorf("Expected each remote to have its own headers, got %v and %v", remotes[0].Headers, remotes[1].Headers)
+	}
+
+	cfg.Remotes = `[{"token": "*:production.abc", "headers": {"X-Tenant": "env:OVERLEASH_TEST_MISSING"}}]`
+
+	if _, err := cfg.RemoteConfigs(); err == nil {
+		t.Error("Expected a missing environment variable to be an error")
+	}
+}
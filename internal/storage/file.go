@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -67,3 +68,22 @@ func (f *FileStore) Write(filename string, data []byte) (writeErr error) {
 	_, writeErr = file.Write(data)
 	return writeErr
 }
+
+// Ping reports whether a file can be written to the data directory.
+func (f *FileStore) Ping(ctx context.Context) error {
+	dir := f.dataDir()
+
+	if err := os.MkdirAll(dir, 0771); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, ".ping-*")
+
+	if err != nil {
+		return err
+	}
+
+	file.Close()
+
+	return os.Remove(file.Name())
+}
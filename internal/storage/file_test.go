@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -121,3 +122,25 @@ func TestDataDirResolution(t *testing.T) {
 		t.Errorf("Expected fallback dataDir %q, got %q", expected, dir)
 	}
 }
+
+// TestFileStore_Ping verifies that pinging creates the data directory and
+// leaves no file behind.
+func TestFileStore_Ping(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv(dataDir, tmpDir)
+
+	fs := NewFileStore()
+
+	if err := fs.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "overleash"))
+	if err != nil {
+		t.Fatalf("Expected the data directory to exist: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left behind, got %d", len(entries))
+	}
+}
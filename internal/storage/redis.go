@@ -79,6 +79,10 @@ func (r *RedisStore) Write(key string, data []byte) error {
 	return r.client.Publish(ctx, r.pubsubCh, b).Err()
 }
 
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *RedisStore) Broadcast(key string, data []byte) error {
 	ctx := context.Background()
 
@@ -23,6 +23,13 @@ type BroadcastStore interface {
 	Broadcast(key string, data []byte) error
 }
 
+// PingStore is a store whose backend can become unavailable. Ping reports
+// whether it is reachable and usable.
+type PingStore interface {
+	Store
+	Ping(ctx context.Context) error
+}
+
 func NewStoreFromConfig(cfg *config.Config) Store {
 	backend := cfg.Storage
 
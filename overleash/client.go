@@ -3,13 +3,13 @@ package overleash
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Iandenh/overleash/internal/version"
@@ -29,35 +29,38 @@ const (
 )
 
 type client interface {
-	getFeatures(token string) (*FeatureFile, error)
+	getFeatures(token string, etag string) (*FeatureFile, string, error)
+	getDelta(token string, revision string) (*Events, string, error)
 	validateToken(token string) (*EdgeToken, error)
 	registerClient(token *EdgeToken) error
 	bulkMetrics(token string, applications []*ClientData, metrics []*MetricsData) error
-	streamFeatures(token string, channel chan eventsource.Event) error
+	// streamFeatures streams the features of token into channel. Failures of
+	// the stream are sent to errs without blocking; it keeps reconnecting.
+	streamFeatures(token string, channel chan eventsource.Event, errs chan<- error) error
 }
 
 type overleashClient struct {
 	ctx          context.Context
-	upstream     string
+	upstreams    *upstreamPool
 	httpClient   *http.Client
+	streamClient *http.Client
 	connectionId string
 	interval     int
 }
 
-func newClient(upstream string, interval time.Duration, ctx context.Context) *overleashClient {
+// newClient returns a client for upstreams that sends its requests through
+// httpClient. Streams use the same transport without the request timeout.
+func newClient(upstreams *upstreamPool, interval time.Duration, httpClient *http.Client, ctx context.Context) *overleashClient {
+	streamClient := *httpClient
+	streamClient.Timeout = 0
+
 	return &overleashClient{
-		upstream:     upstream,
+		upstreams:    upstreams,
 		interval:     int(interval.Seconds()),
 		connectionId: uuid.New().String(),
-		httpClient: &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{},
-			},
-		},
-		ctx: ctx,
+		httpClient:   httpClient,
+		streamClient: &streamClient,
+		ctx:          ctx,
 	}
 }
 
@@ -148,11 +151,101 @@ type validationResponse struct {
 	Tokens []*EdgeToken `json:"tokens"`
 }
 
-func (c *overleashClient) getFeatures(token string) (*FeatureFile, error) {
-	req, err := http.NewRequest(http.MethodGet, c.upstream+"/api/client/features", nil)
+// errNotModified is returned by getFeatures when the upstream answers 304 to
+// the ETag it was given.
+var errNotModified = errors.New("features not modified")
+
+// maxUpstreamErrorBody is how much of an error response is kept in an
+// UpstreamError.
+const maxUpstreamErrorBody = 256
+
+// UpstreamError is returned when the upstream answers with a status other than
+// 2xx, e.g. for an invalid token or while it is unavailable.
+type UpstreamError struct {
+	Url        string
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the upstream asked to wait before retrying.
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("upstream %s responded with %d %s", e.Url, e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+
+	return msg
+}
+
+func newUpstreamError(req *http.Request, res *http.Response) *UpstreamError {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxUpstreamErrorBody))
+
+	return &UpstreamError{
+		Url:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// withFailover calls request with the upstreams in order of preference until
+// one succeeds or reports errNotModified. The errors of all failed upstreams
+// are returned together.
+func (c *overleashClient) withFailover(request func(upstream string) error) error {
+	errs := make([]error, 0)
+
+	for _, i := range c.upstreams.candidates(time.Now()) {
+		err := request(c.upstreams.url(i))
+
+		if err == nil || errors.Is(err, errNotModified) {
+			if c.upstreams.succeeded(i) {
+				log.Infof("Switched to upstream %s", c.upstreams.url(i))
+			}
+
+			return err
+		}
+
+		if c.upstreams.len() > 1 {
+			log.Warnf("Upstream %s failed: %v", c.upstreams.url(i), err)
+		}
+
+		c.upstreams.failed(i, time.Now())
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// getFeatures fetches the feature file for token from the first upstream that
+// responds, in order of preference. When etag is the ETag of the feature file
+// the caller already has and the upstream did not change it, errNotModified is
+// returned. The returned string is the ETag of the returned feature file, if
+// the upstream sent one.
+func (c *overleashClient) getFeatures(token string, etag string) (*FeatureFile, string, error) {
+	var features *FeatureFile
+	newEtag := etag
+
+	err := c.withFailover(func(upstream string) error {
+		var err error
+		features, newEtag, err = c.getFeaturesFrom(upstream, token, etag)
+
+		return err
+	})
+
+	if err != nil && !errors.Is(err, errNotModified) {
+		return nil, "", err
+	}
+
+	return features, newEtag, err
+}
+
+func (c *overleashClient) getFeaturesFrom(upstream string, token string, etag string) (*FeatureFile, string, error) {
+	req, err := http.NewRequest(http.MethodGet, upstream+"/api/client/features", nil)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	req.Header.Add("Accept", "application/json")
@@ -163,22 +256,34 @@ func (c *overleashClient) getFeatures(token string) (*FeatureFile, error) {
 	req.Header.Add(unleashIntervalHeader, strconv.Itoa(c.interval))
 	req.Header.Add(unleashSdkHeader, "overleash@"+version.Version)
 
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+
 	res, err := c.httpClient.Do(req)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if res == nil {
-		return nil, fmt.Errorf("httpClient.Do returned nil response without error")
+		return nil, "", fmt.Errorf("httpClient.Do returned nil response without error")
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotModified {
+		return nil, etag, errNotModified
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, "", newUpstreamError(req, res)
+	}
+
 	response, err := io.ReadAll(res.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	features := &FeatureFile{}
@@ -186,14 +291,93 @@ func (c *overleashClient) getFeatures(token string) (*FeatureFile, error) {
 	err = json.Unmarshal(response, features)
 
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("invalid feature file from upstream: %w", err)
+	}
+
+	return features, res.Header.Get("ETag"), nil
+}
+
+// getDelta polls the delta API for the changes since revision, the revision
+// of the last delta the caller applied. Without a revision the upstream starts
+// with a hydration. When nothing changed, errNotModified is returned. The
+// returned string is the revision of the returned delta.
+func (c *overleashClient) getDelta(token string, revision string) (*Events, string, error) {
+	var events *Events
+	newRevision := revision
+
+	err := c.withFailover(func(upstream string) error {
+		var err error
+		events, newRevision, err = c.getDeltaFrom(upstream, token, revision)
+
+		return err
+	})
+
+	if err != nil && !errors.Is(err, errNotModified) {
+		return nil, "", err
+	}
+
+	return events, newRevision, err
+}
+
+func (c *overleashClient) getDeltaFrom(upstream string, token string, revision string) (*Events, string, error) {
+	req, err := http.NewRequest(http.MethodGet, upstream+"/api/client/delta", nil)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", token)
+	req.Header.Add(unleashClientSpecHeader, supportedSpecVersion)
+	req.Header.Add(unleashAppNameHeader, "Overleash")
+	req.Header.Add(unleashConnectionIdHeader, c.connectionId)
+	req.Header.Add(unleashIntervalHeader, strconv.Itoa(c.interval))
+	req.Header.Add(unleashSdkHeader, "overleash@"+version.Version)
+
+	if revision != "" {
+		req.Header.Add("If-None-Match", revision)
+	}
+
+	res, err := c.httpClient.Do(req)
+
+	if err != nil {
+		return nil, "", err
 	}
 
-	return features, nil
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, revision, errNotModified
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, "", newUpstreamError(req, res)
+	}
+
+	response, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	events := &Events{}
+
+	if err := json.Unmarshal(response, events); err != nil {
+		return nil, "", fmt.Errorf("invalid delta from upstream: %w", err)
+	}
+
+	newRevision := res.Header.Get("ETag")
+
+	// The revision is the id of the last event when no ETag is sent.
+	if newRevision == "" {
+		newRevision = eventsRevision(*events)
+	}
+
+	return events, newRevision, nil
 }
 
 func (c *overleashClient) validateToken(token string) (*EdgeToken, error) {
-	req, err := http.NewRequest(http.MethodPost, c.upstream+"/edge/validate", nil)
+	req, err := http.NewRequest(http.MethodPost, c.upstreams.serving()+"/edge/validate", nil)
 
 	if err != nil {
 		return nil, err
@@ -252,7 +436,7 @@ func (c *overleashClient) validateToken(token string) (*EdgeToken, error) {
 }
 
 func (c *overleashClient) registerClient(token *EdgeToken) error {
-	req, err := http.NewRequest(http.MethodPost, c.upstream+"/api/client/register", nil)
+	req, err := http.NewRequest(http.MethodPost, c.upstreams.serving()+"/api/client/register", nil)
 
 	if err != nil {
 		return err
@@ -305,7 +489,7 @@ func (c *overleashClient) registerClient(token *EdgeToken) error {
 }
 
 func (c *overleashClient) bulkMetrics(token string, applications []*ClientData, metrics []*MetricsData) error {
-	req, err := http.NewRequest(http.MethodPost, c.upstream+"/api/client/metrics/bulk", nil)
+	req, err := http.NewRequest(http.MethodPost, c.upstreams.serving()+"/api/client/metrics/bulk", nil)
 	if err != nil {
 		return err
 	}
@@ -354,11 +538,100 @@ func (c *overleashClient) bulkMetrics(token string, applications []*ClientData,
 	return nil
 }
 
-func (c *overleashClient) streamFeatures(token string, channel chan eventsource.Event) error {
-	req, err := http.NewRequest(http.MethodGet, c.upstream+"/api/client/streaming", nil)
+// streamFeatures streams the features of token into channel without blocking
+// until connected. With a single upstream the stream reconnects to it forever.
+// With several upstreams a failed stream moves on to the next upstream, and a
+// stream served by a fallback moves back to the preferred upstream once its
+// backoff expired.
+func (c *overleashClient) streamFeatures(token string, channel chan eventsource.Event, errs chan<- error) error {
+	if c.upstreams.len() == 1 {
+		go func() {
+			stream, err := c.subscribe(c.upstreams.url(0), token, false, errs)
+
+			if err != nil {
+				reportStreamError(errs, err)
+				return
+			}
+
+			c.forward(stream, channel, nil)
+		}()
+
+		return nil
+	}
+
+	go c.streamWithFailover(token, channel, errs)
+
+	return nil
+}
+
+// reportStreamError sends err to errs, unless an earlier error is still
+// pending.
+func reportStreamError(errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	default:
+	}
+}
+
+func (c *overleashClient) streamWithFailover(token string, channel chan eventsource.Event, errs chan<- error) {
+	for c.ctx.Err() == nil {
+		connected := false
+		failures := make([]error, 0)
+
+		for _, i := range c.upstreams.candidates(time.Now()) {
+			upstream := c.upstreams.url(i)
+			stream, err := c.subscribe(upstream, token, true, errs)
+
+			if err != nil {
+				log.Warnf("Unable to stream from upstream %s: %v", upstream, err)
+				c.upstreams.failed(i, time.Now())
+				failures = append(failures, err)
+				continue
+			}
+
+			connected = true
+
+			if c.upstreams.succeeded(i) {
+				log.Infof("Switched stream to upstream %s", upstream)
+			}
+
+			var failback <-chan time.Time
+
+			if delay, ok := c.upstreams.failbackIn(time.Now()); ok {
+				failback = time.After(delay)
+			}
+
+			if c.forward(stream, channel, failback) {
+				log.Warnf("Stream from upstream %s failed", upstream)
+				c.upstreams.failed(i, time.Now())
+				reportStreamError(errs, fmt.Errorf("stream from upstream %s failed", upstream))
+			}
+
+			break
+		}
+
+		if connected {
+			continue
+		}
+
+		reportStreamError(errs, errors.Join(failures...))
+
+		select {
+		case <-time.After(backoff(upstreamFailoverBackoff, 1)):
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+// subscribe opens a stream from upstream. With failover the first connection is
+// not retried and the stream is closed on the first error, so the caller can
+// move on to the next upstream. Otherwise errors are reported to errs while
+// the stream reconnects.
+func (c *overleashClient) subscribe(upstream string, token string, failover bool, errs chan<- error) (*eventsource.Stream, error) {
+	req, err := http.NewRequest(http.MethodGet, upstream+"/api/client/streaming", nil)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	overleashVersion := "overleash@" + version.Version
@@ -372,35 +645,60 @@ func (c *overleashClient) streamFeatures(token string, channel chan eventsource.
 	req.Header.Add(unleashIntervalHeader, strconv.Itoa(c.interval))
 	req.Header.Add(unleashSdkHeader, overleashVersion)
 
-	stream, err := eventsource.SubscribeWithRequestAndOptions(req,
-		eventsource.StreamOptionCanRetryFirstConnection(-time.Second*3),
-		eventsource.StreamOptionUseBackoff(5*time.Minute),
+	options := []eventsource.StreamOption{
+		eventsource.StreamOptionHTTPClient(c.streamClient),
+		eventsource.StreamOptionUseBackoff(5 * time.Minute),
 		eventsource.StreamOptionUseJitter(0.5),
 		eventsource.StreamOptionErrorHandler(func(err error) eventsource.StreamErrorHandlerResult {
-			return eventsource.StreamErrorHandlerResult{CloseNow: false}
+			if !failover {
+				reportStreamError(errs, err)
+			}
+
+			return eventsource.StreamErrorHandlerResult{CloseNow: failover}
 		}),
-	)
+	}
+
+	if !failover {
+		options = append(options, eventsource.StreamOptionCanRetryFirstConnection(-time.Second*3))
+	}
+
+	return eventsource.SubscribeWithRequestAndOptions(req, options...)
+}
+
+// forward passes the events of stream to channel until the context is done,
+// failback fires or the stream is closed. It reports whether the stream was
+// closed because it failed.
+func (c *overleashClient) forward(stream *eventsource.Stream, channel chan eventsource.Event, failback <-chan time.Time) (failed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("SSE subscription panic recovered: %v", r)
+			log.Error(err.Error())
+		}
+	}()
 
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				err := fmt.Errorf("SSE subscription panic recovered: %v", r)
-				log.Error(err.Error())
+	for {
+		select {
+		case event, ok := <-stream.Events:
+			if !ok {
+				return true
+			}
+
+			if event == nil {
+				continue
 			}
-		}()
 
-		for {
 			select {
-			case event := <-stream.Events:
-				if event != nil {
-					channel <- event
-				}
+			case channel <- event:
 			case <-c.ctx.Done():
 				stream.Close()
-				return
+				return false
 			}
+		case <-failback:
+			stream.Close()
+			return false
+		case <-c.ctx.Done():
+			stream.Close()
+			return false
 		}
-	}()
-
-	return nil
+	}
 }
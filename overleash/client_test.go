@@ -3,11 +3,14 @@ package overleash
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/Iandenh/overleash/internal/httpclient"
 )
 
 // TestGetFeatures tests the getFeatures method.
@@ -49,8 +52,8 @@ func TestGetFeatures(t *testing.T) {
 	defer ts.Close()
 
 	// Create a new overleashClient with a dummy interval.
-	c := newClient(ts.URL, 1, context.Background())
-	features, err := c.getFeatures("dummy-token")
+	c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
+	features, _, err := c.getFeatures("dummy-token", "")
 	if err != nil {
 		t.Fatalf("getFeatures returned error: %v", err)
 	}
@@ -74,6 +77,136 @@ func TestGetFeatures(t *testing.T) {
 	}
 }
 
+func TestGetFeaturesSendsIfNoneMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"version":1,"features":[{"name":"a"}]}`))
+	}))
+	defer ts.Close()
+
+	c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
+
+	features, etag, err := c.getFeatures("dummy-token", "")
+	if err != nil {
+		t.Fatalf("getFeatures returned error: %v", err)
+	}
+	if etag != `"v1"` || len(features.Features) != 1 {
+		t.Fatalf("Expected one feature with etag \"v1\", got %v with etag %s", features, etag)
+	}
+
+	features, etag, err = c.getFeatures("dummy-token", etag)
+	if !errors.Is(err, errNotModified) {
+		t.Fatalf("Expected errNotModified, got %v", err)
+	}
+	if features != nil || etag != `"v1"` {
+		t.Errorf("Expected no features and the same etag, got %v with etag %s", features, etag)
+	}
+}
+
+func TestGetFeaturesRejectsErrorResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "invalid token", status: http.StatusUnauthorized, body: "<html>Unauthorized</html>"},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"features":[]}`},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
+			features, _, err := c.getFeatures("dummy-token", "")
+
+			var upstreamErr *UpstreamError
+			if !errors.As(err, &upstreamErr) {
+				t.Fatalf("Expected an UpstreamError, got %v", err)
+			}
+			if upstreamErr.StatusCode != tt.status || upstreamErr.Body != tt.body {
+				t.Errorf("Expected status %d with body %q, got %d with %q", tt.status, tt.body, upstreamErr.StatusCode, upstreamErr.Body)
+			}
+			if features != nil {
+				t.Errorf("Expected no features, got %v", features)
+			}
+		})
+	}
+}
+
+func TestGetFeaturesRejectsInvalidJson(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>Maintenance</html>"))
+	}))
+	defer ts.Close()
+
+	c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
+
+	if _, _, err := c.getFeatures("dummy-token", ""); err == nil {
+		t.Fatal("Expected an error for a body that is not JSON")
+	}
+}
+
+// deltaUpstream serves the delta API: a hydration without revision, then an
+// update without ETag, after which nothing changes.
+func deltaUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/client/delta" {
+			t.Errorf("Expected path /api/client/delta, got %s", r.URL.Path)
+		}
+
+		switch r.Header.Get("If-None-Match") {
+		case "":
+			w.Header().Set("ETag", `"1"`)
+			w.Write([]byte(`{"events":[{"type":"hydration","eventId":1,"features":[{"name":"a","enabled":true},{"name":"b","enabled":true}],"segments":[]}]}`))
+		case `"1"`:
+			w.Write([]byte(`{"events":[{"type":"feature-removed","eventId":2,"featureName":"b","project":"default"},{"type":"feature-updated","eventId":3,"feature":{"name":"c","enabled":true}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestGetDelta(t *testing.T) {
+	c := newClient(newUpstreamPool(deltaUpstream(t).URL), 1, httpclient.New(nil, 0), context.Background())
+
+	events, revision, err := c.getDelta("dummy-token", "")
+	if err != nil {
+		t.Fatalf("getDelta returned error: %v", err)
+	}
+	if revision != `"1"` || len(events.Events) != 1 || events.Events[0].GetType() != "hydration" {
+		t.Fatalf("Expected a hydration with revision \"1\", got %v with revision %s", events.Events, revision)
+	}
+
+	events, revision, err = c.getDelta("dummy-token", revision)
+	if err != nil {
+		t.Fatalf("getDelta returned error: %v", err)
+	}
+	if revision != `"3"` || len(events.Events) != 2 {
+		t.Fatalf("Expected two events with the last event id as revision, got %v with revision %s", events.Events, revision)
+	}
+
+	events, revision, err = c.getDelta("dummy-token", revision)
+	if !errors.Is(err, errNotModified) || events != nil || revision != `"3"` {
+		t.Errorf("Expected errNotModified with the same revision, got %v with revision %s", err, revision)
+	}
+}
+
 // TestValidateToken tests the validateToken method.
 func TestValidateToken(t *testing.T) {
 	expectedEdgeToken := EdgeToken{
@@ -113,7 +246,7 @@ func TestValidateToken(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	c := newClient(ts.URL, 1, context.Background())
+	c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
 	token, err := c.validateToken("dummy-token")
 	if err != nil {
 		t.Fatalf("validateToken returned error: %v", err)
@@ -157,7 +290,7 @@ func TestRegisterClient(t *testing.T) {
 			t.Errorf("Expected SdkVersion to start with 'overleash@', got %s", reqData.SdkVersion)
 		}
 		// Check that the interval is as expected.
-		expectedInterval := newClient("", 1, context.Background()).interval
+		expectedInterval := newClient(newUpstreamPool(""), 1, httpclient.New(nil, 0), context.Background()).interval
 		if reqData.Interval != expectedInterval {
 			t.Errorf("Expected Interval %d, got %d", expectedInterval, reqData.Interval)
 		}
@@ -166,7 +299,7 @@ func TestRegisterClient(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	c := newClient(ts.URL, 1, context.Background())
+	c := newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background())
 	dummyToken := &EdgeToken{
 		Token:       "valid-token",
 		Environment: "test",
@@ -183,7 +316,7 @@ func TestRegisterClient(t *testing.T) {
 	}))
 	defer tsFail.Close()
 
-	cFail := newClient(tsFail.URL, 1, context.Background())
+	cFail := newClient(newUpstreamPool(tsFail.URL), 1, httpclient.New(nil, 0), context.Background())
 	err := cFail.registerClient(dummyToken)
 	if err == nil {
 		t.Fatalf("Expected error due to non-OK status code, got nil")
@@ -0,0 +1,66 @@
+package overleash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+)
+
+// SupportedEncodings lists the encodings the cached feature JSON is
+// pre-compressed in, in order of preference.
+var SupportedEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// compressJson compresses data once per supported encoding. An encoding that
+// fails is left out, so the caller falls back to another one.
+func compressJson(data []byte) map[string][]byte {
+	compressed := make(map[string][]byte, len(SupportedEncodings))
+
+	for _, encoding := range SupportedEncodings {
+		buf := new(bytes.Buffer)
+
+		writer, err := newCompressWriter(encoding, buf)
+
+		if err != nil {
+			continue
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			continue
+		}
+
+		if err := writer.Close(); err != nil {
+			continue
+		}
+
+		compressed[encoding] = buf.Bytes()
+	}
+
+	return compressed
+}
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case EncodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	default:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	}
+}
+
+// CompressedJson returns the cached feature JSON compressed with encoding.
+func (fe *FeatureEnvironment) CompressedJson(encoding string) ([]byte, bool) {
+	data, ok := fe.compressedJson[encoding]
+
+	return data, ok
+}
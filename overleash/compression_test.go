@@ -0,0 +1,74 @@
+package overleash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestCompileCompressesCachedJson verifies that every supported encoding is
+// produced at compile time and decodes back to the cached JSON.
+func TestCompileCompressesCachedJson(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Token:    "dummy.token",
+		Storage:  "file",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.ActiveFeatureEnvironment().featureFile = FeatureFile{
+		Version: 1,
+		Features: FeatureFlags{
+			{Name: "feature1", Enabled: true, Strategies: []Strategy{{Name: "default"}}},
+		},
+	}
+	o.compileFeatureFiles()
+
+	env := o.ActiveFeatureEnvironment()
+
+	for _, encoding := range SupportedEncodings {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, ok := env.CompressedJson(encoding)
+			if !ok {
+				t.Fatalf("Expected a %s variant of the cached JSON", encoding)
+			}
+
+			var reader io.Reader
+			switch encoding {
+			case EncodingBrotli:
+				reader = brotli.NewReader(bytes.NewReader(compressed))
+			case EncodingZstd:
+				decoder, err := zstd.NewReader(bytes.NewReader(compressed))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer decoder.Close()
+				reader = decoder
+			case EncodingGzip:
+				gz, err := gzip.NewReader(bytes.NewReader(compressed))
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader = gz
+			}
+
+			decoded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to decode %s: %v", encoding, err)
+			}
+			if !bytes.Equal(decoded, env.CachedJson()) {
+				t.Errorf("Decoded %s variant does not match the cached JSON", encoding)
+			}
+		})
+	}
+
+	if _, ok := env.CompressedJson("deflate"); ok {
+		t.Error("Did not expect a variant for an unsupported encoding")
+	}
+}
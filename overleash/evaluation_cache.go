@@ -0,0 +1,292 @@
+package overleash
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/Iandenh/overleash/unleashengine"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxCachedEvaluations bounds the evaluation cache of a single environment.
+// Once full the cache starts over, which is cheaper than tracking usage for
+// what is mostly a handful of contexts per browser.
+const maxCachedEvaluations = 10000
+
+// Evaluation is the result of evaluating all toggles for one context.
+type Evaluation struct {
+	Toggles *unleashengine.EvaluatedToggleList
+	Json    []byte
+	Etag    string
+}
+
+type evaluationCache struct {
+	mutex       sync.RWMutex
+	version     uint64
+	entries     map[string]*Evaluation
+	determinism determinism
+}
+
+func (c *evaluationCache) reset(version uint64, d determinism) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.version = version
+	c.entries = make(map[string]*Evaluation)
+	c.determinism = d
+}
+
+func (c *evaluationCache) get(key string) (*Evaluation, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	evaluation, ok := c.entries[key]
+
+	return evaluation, ok
+}
+
+func (c *evaluationCache) put(version uint64, key string, evaluation *Evaluation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// The state was recompiled while evaluating, so the result is already stale.
+	if c.version != version {
+		return
+	}
+
+	if len(c.entries) >= maxCachedEvaluations {
+		c.entries = make(map[string]*Evaluation)
+	}
+
+	c.entries[key] = evaluation
+}
+
+// Version is incremented every time the environment is compiled.
+func (fe *FeatureEnvironment) Version() uint64 {
+	return fe.version
+}
+
+// ResolveAll evaluates all toggles for ctx. Results are cached per compiled
+// version and normalised context; the second return value reports whether the
+// result was served from the cache.
+//
+// Contexts whose result depends on the clock or on randomness are never
+// cached, see determinism.
+func (fe *FeatureEnvironment) ResolveAll(ctx *unleashengine.Context, includeAll bool) (*Evaluation, bool, error) {
+	fe.evaluations.mutex.RLock()
+	version := fe.evaluations.version
+	cacheable := fe.evaluations.determinism.cacheable(ctx)
+	usesRemoteAddress := fe.evaluations.determinism.remoteAddress
+	fe.evaluations.mutex.RUnlock()
+
+	key := ""
+
+	if cacheable {
+		keyCtx := ctx
+
+		if !usesRemoteAddress && ctx.RemoteAddress != nil {
+			keyCtx = proto.CloneOf(ctx)
+			keyCtx.RemoteAddress = nil
+		}
+
+		contextBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(keyCtx)
+
+		if err != nil {
+			cacheable = false
+		} else {
+			key = fe.environment + "|" + strconv.FormatUint(version, 10) + "|" + strconv.FormatBool(includeAll) + "|" + string(contextBytes)
+		}
+	}
+
+	if cacheable {
+		if evaluation, ok := fe.evaluations.get(key); ok {
+			return evaluation, true, nil
+		}
+	}
+
+	evaluation, err := newEvaluation(fe.engine, ctx, includeAll)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cacheable {
+		fe.evaluations.put(version, key, evaluation)
+	}
+
+	return evaluation, false, nil
+}
+
+// newEvaluation evaluates all toggles for ctx on engine, bypassing any cache.
+func newEvaluation(engine unleashengine.Engine, ctx *unleashengine.Context, includeAll bool) (*Evaluation, error) {
+	toggles, err := engine.ResolveAll(ctx, includeAll)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(toggles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluation{
+		Toggles: toggles,
+		Json:    data,
+		Etag:    calculateETag(data),
+	}, nil
+}
+
+// ResolveAll evaluates all toggles of the request state. Request states are
+// short-lived, so nothing is cached.
+func (s *RequestState) ResolveAll(ctx *unleashengine.Context, includeAll bool) (*Evaluation, error) {
+	engine, err := s.Engine()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newEvaluation(engine, ctx, includeAll)
+}
+
+// determinism records what, besides the context, the evaluation of a feature
+// file depends on.
+type determinism struct {
+	// clock is set when a constraint compares against the current time.
+	clock bool
+	// random is set when a strategy or variant is picked at random whatever the
+	// context.
+	random bool
+	// stickiness is set when the result is random unless the context has a
+	// userId or sessionId, as with the "default" stickiness.
+	stickiness bool
+	// remoteAddress is set when a strategy, constraint or stickiness uses the
+	// remote address. Otherwise it is left out of the cache key, as the
+	// frontend API fills it in for every client.
+	remoteAddress bool
+}
+
+func (d determinism) cacheable(ctx *unleashengine.Context) bool {
+	if d.random {
+		return false
+	}
+
+	if d.clock && ctx.CurrentTime == nil {
+		return false
+	}
+
+	if d.stickiness && ctx.UserId == nil && ctx.SessionId == nil {
+		return false
+	}
+
+	return true
+}
+
+func analyseDeterminism(file FeatureFile) determinism {
+	d := determinism{}
+
+	for _, segment := range file.Segments {
+		d.constraints(segment.Constraints)
+	}
+
+	for _, feature := range file.Features {
+		if !feature.Enabled {
+			continue
+		}
+
+		d.variants(len(feature.Variants), func(i int) (int, string) {
+			return feature.Variants[i].Weight, feature.Variants[i].Stickiness
+		})
+
+		for _, strategy := range feature.Strategies {
+			d.constraints(strategy.Constraints)
+
+			switch strategy.Name {
+			case "remoteAddress":
+				d.remoteAddress = true
+			case "gradualRolloutRandom":
+				if isPartialRollout(strategy.Parameters["percentage"]) {
+					d.random = true
+				}
+			case "flexibleRollout":
+				if isPartialRollout(strategy.Parameters["rollout"]) {
+					d.stick(strategy.Parameters["stickiness"])
+				}
+			}
+
+			d.variants(len(strategy.Variants), func(i int) (int, string) {
+				return strategy.Variants[i].Weight, strategy.Variants[i].Stickiness
+			})
+		}
+	}
+
+	return d
+}
+
+func (d *determinism) constraints(constraints []Constraint) {
+	for _, constraint := range constraints {
+		if constraint.Operator == OperatorDateBefore || constraint.Operator == OperatorDateAfter || constraint.ContextName == "currentTime" {
+			d.clock = true
+		}
+
+		if constraint.ContextName == "remoteAddress" {
+			d.remoteAddress = true
+		}
+	}
+}
+
+func (d *determinism) variants(count int, variant func(i int) (int, string)) {
+	weighted := 0
+	stickiness := ""
+
+	for i := 0; i < count; i++ {
+		weight, s := variant(i)
+
+		if weight > 0 {
+			weighted++
+			stickiness = s
+		}
+	}
+
+	if weighted > 1 {
+		d.stick(stickiness)
+	}
+}
+
+func (d *determinism) stick(stickiness any) {
+	s, _ := stickiness.(string)
+
+	switch s {
+	case "random":
+		d.random = true
+	case "", "default":
+		d.stickiness = true
+	case "remoteAddress":
+		d.remoteAddress = true
+	}
+}
+
+// isPartialRollout reports whether a rollout percentage is strictly between 0
+// and 100, which is when stickiness decides the outcome.
+func isPartialRollout(value any) bool {
+	var percentage float64
+
+	switch v := value.(type) {
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+
+		if err != nil {
+			return false
+		}
+
+		percentage = parsed
+	case float64:
+		percentage = v
+	default:
+		return false
+	}
+
+	return percentage > 0 && percentage < 100
+}
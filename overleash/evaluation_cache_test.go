@@ -0,0 +1,188 @@
+package overleash
+
+import (
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/unleashengine"
+)
+
+// countingEngine counts how often toggles are evaluated.
+type countingEngine struct {
+	fakeEngine
+	calls int
+}
+
+func (ce *countingEngine) ResolveAll(context *unleashengine.Context, includeAll bool) (*unleashengine.EvaluatedToggleList, error) {
+	ce.calls++
+
+	return ce.fakeEngine.ResolveAll(context, includeAll)
+}
+
+func newEvaluationTestOverleash(t *testing.T, featureFile FeatureFile) (*OverleashContext, *countingEngine) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Token:    "dummy.token",
+		Storage:  "file",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.store = &fakeStore{}
+
+	engine := &countingEngine{}
+	o.ActiveFeatureEnvironment().engine = engine
+	o.ActiveFeatureEnvironment().featureFile = featureFile
+	o.compileFeatureFiles()
+
+	return o, engine
+}
+
+func userContext(userId string) *unleashengine.Context {
+	return &unleashengine.Context{UserId: &userId, Properties: map[string]string{"a": "1", "b": "2"}}
+}
+
+func TestResolveAllIsCachedPerContext(t *testing.T) {
+	o, engine := newEvaluationTestOverleash(t, FeatureFile{
+		Features: FeatureFlags{{Name: "feature1", Enabled: true, Strategies: []Strategy{{Name: "default"}}}},
+	})
+	env := o.ActiveFeatureEnvironment()
+
+	first, hit, err := env.ResolveAll(userContext("1"), false)
+	if err != nil || hit {
+		t.Fatalf("Expected a miss on first evaluation, got hit=%v err=%v", hit, err)
+	}
+
+	second, hit, err := env.ResolveAll(userContext("1"), false)
+	if err != nil || !hit {
+		t.Fatalf("Expected a hit for the same context, got hit=%v err=%v", hit, err)
+	}
+	if first.Etag != second.Etag || engine.calls != 1 {
+		t.Errorf("Expected the cached evaluation to be reused, engine called %d times", engine.calls)
+	}
+
+	if _, hit, _ := env.ResolveAll(userContext("2"), false); hit {
+		t.Error("Expected a miss for a different context")
+	}
+	if _, hit, _ := env.ResolveAll(userContext("1"), true); hit {
+		t.Error("Expected includeAll to be part of the cache key")
+	}
+
+	// Any change recompiles, which must invalidate the cache.
+	o.AddOverride("feature1", false)
+
+	if _, hit, _ := env.ResolveAll(userContext("1"), false); hit {
+		t.Error("Expected compile to invalidate the cache")
+	}
+}
+
+func TestResolveAllSharesEntriesAcrossRemoteAddresses(t *testing.T) {
+	withAddress := func(addr string) *unleashengine.Context {
+		ctx := userContext("1")
+		ctx.RemoteAddress = &addr
+
+		return ctx
+	}
+
+	o, engine := newEvaluationTestOverleash(t, FeatureFile{
+		Features: FeatureFlags{{Name: "feature1", Enabled: true, Strategies: []Strategy{{Name: "default"}}}},
+	})
+	env := o.ActiveFeatureEnvironment()
+
+	env.ResolveAll(withAddress("10.0.0.1"), false)
+
+	if _, hit, _ := env.ResolveAll(withAddress("10.0.0.2"), false); !hit || engine.calls != 1 {
+		t.Errorf("Expected clients with the same context to share an entry, engine called %d times", engine.calls)
+	}
+
+	o, _ = newEvaluationTestOverleash(t, FeatureFile{
+		Features: FeatureFlags{{Name: "feature1", Enabled: true, Strategies: []Strategy{{Name: "remoteAddress", Parameters: ParameterMap{"IPs": "10.0.0.1"}}}}},
+	})
+	env = o.ActiveFeatureEnvironment()
+
+	env.ResolveAll(withAddress("10.0.0.1"), false)
+
+	if _, hit, _ := env.ResolveAll(withAddress("10.0.0.2"), false); hit {
+		t.Error("Expected the remote address to be part of the key when a strategy uses it")
+	}
+}
+
+func TestResolveAllSkipsCacheForNonDeterministicState(t *testing.T) {
+	before := "2020-01-01T00:00:00Z"
+	now := "2026-01-01T00:00:00Z"
+
+	tests := []struct {
+		name      string
+		strategy  Strategy
+		ctx       *unleashengine.Context
+		wantCache bool
+	}{
+		{
+			name:      "standard strategy",
+			strategy:  Strategy{Name: "default"},
+			ctx:       &unleashengine.Context{},
+			wantCache: true,
+		},
+		{
+			name:      "random rollout",
+			strategy:  Strategy{Name: "gradualRolloutRandom", Parameters: ParameterMap{"percentage": "50"}},
+			ctx:       userContext("1"),
+			wantCache: false,
+		},
+		{
+			name:      "default stickiness without ids",
+			strategy:  Strategy{Name: "flexibleRollout", Parameters: ParameterMap{"rollout": "50", "stickiness": "default"}},
+			ctx:       &unleashengine.Context{},
+			wantCache: false,
+		},
+		{
+			name:      "default stickiness with a user id",
+			strategy:  Strategy{Name: "flexibleRollout", Parameters: ParameterMap{"rollout": "50", "stickiness": "default"}},
+			ctx:       userContext("1"),
+			wantCache: true,
+		},
+		{
+			name:      "full rollout",
+			strategy:  Strategy{Name: "flexibleRollout", Parameters: ParameterMap{"rollout": "100", "stickiness": "random"}},
+			ctx:       &unleashengine.Context{},
+			wantCache: true,
+		},
+		{
+			name: "date constraint without current time",
+			strategy: Strategy{Name: "default", Constraints: []Constraint{
+				{ContextName: "currentTime", Operator: OperatorDateAfter, Value: &before},
+			}},
+			ctx:       &unleashengine.Context{},
+			wantCache: false,
+		},
+		{
+			name: "date constraint with current time",
+			strategy: Strategy{Name: "default", Constraints: []Constraint{
+				{ContextName: "currentTime", Operator: OperatorDateAfter, Value: &before},
+			}},
+			ctx:       &unleashengine.Context{CurrentTime: &now},
+			wantCache: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, engine := newEvaluationTestOverleash(t, FeatureFile{
+				Features: FeatureFlags{{Name: "feature1", Enabled: true, Strategies: []Strategy{tt.strategy}}},
+			})
+			env := o.ActiveFeatureEnvironment()
+
+			env.ResolveAll(tt.ctx, false)
+			_, hit, err := env.ResolveAll(tt.ctx, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hit != tt.wantCache {
+				t.Errorf("Expected cached=%v, got %v (engine called %d times)", tt.wantCache, hit, engine.calls)
+			}
+		})
+	}
+}
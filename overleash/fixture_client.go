@@ -0,0 +1,184 @@
+package overleash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/launchdarkly/eventsource"
+	"go.yaml.in/yaml/v3"
+)
+
+// fixtureClient is the client of a remote in offline mode. It reads the
+// feature file from a fixture on disk instead of an upstream.
+type fixtureClient struct {
+	path string
+}
+
+func newFixtureClient(path string) *fixtureClient {
+	return &fixtureClient{path: path}
+}
+
+// getFeatures reads the fixture. The ETag is the hash of its contents, so an
+// unchanged fixture returns errNotModified.
+func (c *fixtureClient) getFeatures(token string, etag string) (*FeatureFile, string, error) {
+	data, err := os.ReadFile(c.path)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	newEtag := calculateETag(data)
+
+	if etag == newEtag {
+		return nil, etag, errNotModified
+	}
+
+	features, err := parseFixture(c.path, data)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return features, newEtag, nil
+}
+
+// parseFixture parses a feature file in JSON, or in YAML when path has a YAML
+// extension. YAML uses the same field names as the JSON of the client API.
+func parseFixture(path string, data []byte) (*FeatureFile, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var v any
+
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+
+		var err error
+		data, err = json.Marshal(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+	}
+
+	features := &FeatureFile{}
+
+	if err := json.Unmarshal(data, features); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+
+	return features, nil
+}
+
+func (c *fixtureClient) getDelta(token string, revision string) (*Events, string, error) {
+	return nil, "", errors.New("the delta API is not available in offline mode")
+}
+
+func (c *fixtureClient) validateToken(token string) (*EdgeToken, error) {
+	return nil, errors.New("tokens cannot be validated in offline mode")
+}
+
+func (c *fixtureClient) registerClient(token *EdgeToken) error {
+	return nil
+}
+
+func (c *fixtureClient) bulkMetrics(token string, applications []*ClientData, metrics []*MetricsData) error {
+	return nil
+}
+
+func (c *fixtureClient) streamFeatures(token string, channel chan eventsource.Event, errs chan<- error) error {
+	return errors.New("streaming is not available in offline mode")
+}
+
+// offlineRemotes returns a remote per fixture. Its token has the environment
+// of the fixture, so SDKs using a token of that environment get its flags.
+func offlineRemotes(fixtures []config.Fixture) []config.Remote {
+	remotes := make([]config.Remote, len(fixtures))
+
+	for i, fixture := range fixtures {
+		remotes[i] = config.Remote{Token: "*:" + fixture.Environment + ".offline"}
+	}
+
+	return remotes
+}
+
+// watchFixtures reloads a fixture whenever it changes. The directories are
+// watched rather than the files, as editors often replace a file on save.
+func (o *OverleashContext) watchFixtures(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return err
+	}
+
+	byPath := make(map[string]*FeatureEnvironment)
+
+	for _, fe := range o.featureEnvironments {
+		if fe.fixture == "" {
+			continue
+		}
+
+		path := filepath.Clean(fe.fixture)
+		byPath[path] = fe
+
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	log.Info("Watching offline fixtures for changes")
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				fe, ok := byPath[filepath.Clean(event.Name)]
+
+				if !ok || !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+
+				o.reloadFixture(fe)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Errorf("Error watching offline fixtures: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (o *OverleashContext) reloadFixture(fe *FeatureEnvironment) {
+	o.LockMutex.Lock()
+	defer o.LockMutex.Unlock()
+
+	// A fixed fixture is read right away instead of after the backoff of a
+	// broken one.
+	if err := o.loadRemotes([]*FeatureEnvironment{fe}, true); err != nil {
+		log.Errorf("Unable to reload fixture %s: %v", fe.fixture, err)
+		return
+	}
+
+	log.Infof("Reloaded fixture %s", fe.fixture)
+}
@@ -0,0 +1,130 @@
+package overleash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+)
+
+const jsonFixture = `{"version": 1, "features": [{"name": "checkout", "enabled": true, "impressionData": true, "strategies": [{"name": "default"}]}]}`
+
+const updatedFixture = `{"version": 1, "features": [{"name": "checkout", "enabled": false}, {"name": "search", "enabled": true}]}`
+
+const yamlFixture = `version: 1
+features:
+  - name: checkout
+    enabled: false
+    impressionData: true
+    strategies:
+      - name: default
+  - name: search
+    enabled: true
+`
+
+func writeFixture(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFixtureClientGetFeatures(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		features int
+	}{
+		{name: "json", file: "development.json", content: jsonFixture, features: 1},
+		{name: "yaml", file: "staging.yaml", content: yamlFixture, features: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			writeFixture(t, path, tt.content)
+
+			c := newFixtureClient(path)
+
+			features, etag, err := c.getFeatures("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(features.Features) != tt.features || !features.Features[0].ImpressionData {
+				t.Fatalf("Expected %d features with impression data, got %+v", tt.features, features.Features)
+			}
+
+			if _, _, err := c.getFeatures("", etag); !errors.Is(err, errNotModified) {
+				t.Errorf("Expected an unchanged fixture not to be modified, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFixtureClientRejectsInvalidFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "development.yaml")
+	writeFixture(t, path, "features: [")
+
+	if _, _, err := newFixtureClient(path).getFeatures("", ""); err == nil {
+		t.Fatal("Expected an invalid fixture to fail")
+	}
+}
+
+func TestOfflineModeServesFixtures(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, filepath.Join(dir, "development.json"), jsonFixture)
+	writeFixture(t, filepath.Join(dir, "staging.yml"), yamlFixture)
+
+	cfg := &config.Config{
+		Offline:      dir,
+		OfflineWatch: true,
+		Storage:      "file",
+		Reload:       "0",
+		Backup:       true,
+	}
+
+	o := NewOverleash(cfg)
+	store := &fakeStore{}
+	o.store = store
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	o.Start(ctx)
+
+	if envs := o.GetRemotes(); len(envs) != 2 {
+		t.Fatalf("Expected a remote per fixture, got %v", envs)
+	}
+
+	development := o.FeatureEnvironments()[0]
+	staging := o.FeatureEnvironments()[1]
+
+	if development.Environment() != "development" || staging.Environment() != "staging" {
+		t.Fatalf("Expected the environments of the file names, got %s and %s", development.Environment(), staging.Environment())
+	}
+
+	if len(development.FeatureFile().Features) != 1 || len(staging.FeatureFile().Features) != 2 {
+		t.Fatalf("Expected the fixtures to be loaded")
+	}
+
+	if _, err := store.Read(development.Name() + "-backup.json"); err == nil {
+		t.Error("Expected fixtures not to be backed up")
+	}
+
+	writeFixture(t, filepath.Join(dir, "development.json"), updatedFixture)
+
+	eventually(t, "the changed fixture to be reloaded", func() bool {
+		o.LockMutex.RLock()
+		defer o.LockMutex.RUnlock()
+
+		return len(development.FeatureFile().Features) == 2
+	})
+}
@@ -0,0 +1,315 @@
+package overleash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// adminSearchPageSize is the number of flags fetched per admin search request.
+const adminSearchPageSize = 500
+
+// FeatureMetadata is what the Unleash admin API knows about a flag beyond the
+// client API: its tags, links, owners, environments and lifecycle stage.
+type FeatureMetadata struct {
+	Project      string
+	ProjectName  string
+	Tags         []FeatureTag
+	Links        []FeatureLink
+	Owners       []string
+	Environments []FeatureMetadataEnvironment
+	// Stage is the lifecycle stage, e.g. "initial", "live" or "completed".
+	Stage string
+}
+
+type FeatureTag struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (t FeatureTag) String() string {
+	return t.Type + ":" + t.Value
+}
+
+type FeatureLink struct {
+	Url   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// Label returns the title of the link, or its URL without one.
+func (l FeatureLink) Label() string {
+	if l.Title != "" {
+		return l.Title
+	}
+
+	return l.Url
+}
+
+type FeatureMetadataEnvironment struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// HasTag reports whether the flag has tag, given as "type:value" or just the
+// value.
+func (m FeatureMetadata) HasTag(tag string) bool {
+	return slices.ContainsFunc(m.Tags, func(t FeatureTag) bool {
+		return strings.EqualFold(t.String(), tag) || strings.EqualFold(t.Value, tag)
+	})
+}
+
+// Matches reports whether term is part of the project, a tag or an owner of the
+// flag.
+func (m FeatureMetadata) Matches(term string) bool {
+	term = strings.ToLower(strings.TrimSpace(term))
+
+	if term == "" {
+		return false
+	}
+
+	values := append([]string{m.Project, m.ProjectName, m.Stage}, m.Owners...)
+
+	for _, tag := range m.Tags {
+		values = append(values, tag.String())
+	}
+
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.Contains(strings.ToLower(v), term)
+	})
+}
+
+type adminProjectsResponse struct {
+	Projects []struct {
+		Id     string `json:"id"`
+		Name   string `json:"name"`
+		Owners []struct {
+			OwnerType string `json:"ownerType"`
+			Name      string `json:"name"`
+		} `json:"owners"`
+	} `json:"projects"`
+}
+
+type adminSearchResponse struct {
+	Features []struct {
+		Name         string                       `json:"name"`
+		Project      string                       `json:"project"`
+		Tags         []FeatureTag                 `json:"tags"`
+		Links        []FeatureLink                `json:"links"`
+		Environments []FeatureMetadataEnvironment `json:"environments"`
+		Lifecycle    *struct {
+			Stage string `json:"stage"`
+		} `json:"lifecycle"`
+		CreatedBy *struct {
+			Name string `json:"name"`
+		} `json:"createdBy"`
+	} `json:"features"`
+	Total int `json:"total"`
+}
+
+// fetchMetadata loads the metadata of all flags from the admin API at
+// adminUrl, keyed by flag name.
+func fetchMetadata(httpClient *http.Client, adminUrl string, token string) (map[string]FeatureMetadata, error) {
+	var projects adminProjectsResponse
+
+	if err := getAdmin(httpClient, adminUrl+"/api/admin/projects", token, &projects); err != nil {
+		return nil, err
+	}
+
+	type project struct {
+		name   string
+		owners []string
+	}
+
+	projectsById := make(map[string]project, len(projects.Projects))
+
+	for _, p := range projects.Projects {
+		owners := make([]string, 0, len(p.Owners))
+
+		for _, owner := range p.Owners {
+			if owner.Name != "" {
+				owners = append(owners, owner.Name)
+			}
+		}
+
+		projectsById[p.Id] = project{name: p.Name, owners: owners}
+	}
+
+	metadata := make(map[string]FeatureMetadata)
+
+	for offset := 0; ; offset += adminSearchPageSize {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(adminSearchPageSize))
+		query.Set("offset", strconv.Itoa(offset))
+
+		var page adminSearchResponse
+
+		if err := getAdmin(httpClient, adminUrl+"/api/admin/search/features?"+query.Encode(), token, &page); err != nil {
+			return nil, err
+		}
+
+		for _, f := range page.Features {
+			p := projectsById[f.Project]
+
+			m := FeatureMetadata{
+				Project:      f.Project,
+				ProjectName:  p.name,
+				Tags:         f.Tags,
+				Links:        f.Links,
+				Owners:       slices.Clone(p.owners),
+				Environments: f.Environments,
+			}
+
+			if f.CreatedBy != nil && f.CreatedBy.Name != "" && !slices.Contains(m.Owners, f.CreatedBy.Name) {
+				m.Owners = append(m.Owners, f.CreatedBy.Name)
+			}
+
+			if f.Lifecycle != nil {
+				m.Stage = f.Lifecycle.Stage
+			}
+
+			metadata[f.Name] = m
+		}
+
+		if len(page.Features) < adminSearchPageSize || offset+len(page.Features) >= page.Total {
+			return metadata, nil
+		}
+	}
+}
+
+func getAdmin(httpClient *http.Client, u string, token string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", token)
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newUpstreamError(req, res)
+	}
+
+	body, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response from admin API: %w", err)
+	}
+
+	return nil
+}
+
+// adminUrl returns the URL of the admin API of the remote.
+func (o *OverleashContext) adminUrl(fe *FeatureEnvironment) string {
+	if o.Config.AdminURL != "" {
+		return o.Config.AdminURL
+	}
+
+	return fe.Upstreams()[0]
+}
+
+// loadMetadata fetches the flag metadata of every remote, once per admin API.
+// A remote whose admin API fails keeps its previous metadata.
+func (o *OverleashContext) loadMetadata() {
+	loaded := make(map[string]map[string]FeatureMetadata)
+
+	for _, fe := range o.featureEnvironments {
+		adminUrl := o.adminUrl(fe)
+		metadata, ok := loaded[adminUrl]
+
+		if !ok {
+			var err error
+			metadata, err = fetchMetadata(fe.httpClient, adminUrl, o.Config.AdminToken)
+
+			if err != nil {
+				log.Errorf("Unable to load flag metadata from %s: %v", adminUrl, err)
+				continue
+			}
+
+			loaded[adminUrl] = metadata
+		}
+
+		fe.metadata.Store(&metadata)
+	}
+}
+
+func (o *OverleashContext) startMetadataFetcher(ctx context.Context) {
+	interval := o.Config.AdminReload
+
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		o.loadMetadata()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				o.loadMetadata()
+			}
+		}
+	}()
+}
+
+// Metadata returns the admin API metadata of the flag name, if loaded.
+func (fe *FeatureEnvironment) Metadata(name string) (FeatureMetadata, bool) {
+	metadata := fe.metadata.Load()
+
+	if metadata == nil {
+		return FeatureMetadata{}, false
+	}
+
+	m, ok := (*metadata)[name]
+
+	return m, ok
+}
+
+// Tags returns all tags of the flags of the environment, sorted.
+func (fe *FeatureEnvironment) Tags() []string {
+	metadata := fe.metadata.Load()
+
+	if metadata == nil {
+		return nil
+	}
+
+	tags := make([]string, 0)
+
+	for _, m := range *metadata {
+		for _, tag := range m.Tags {
+			if !slices.Contains(tags, tag.String()) {
+				tags = append(tags, tag.String())
+			}
+		}
+	}
+
+	slices.Sort(tags)
+
+	return tags
+}
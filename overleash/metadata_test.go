@@ -0,0 +1,169 @@
+package overleash
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Iandenh/overleash/internal/httpclient"
+)
+
+// newFakeAdminApi serves the projects and feature search of the Unleash admin
+// API with count flags named flag-0, flag-1, ...
+func newFakeAdminApi(t *testing.T, count int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/admin/projects", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.Header.Get("Authorization") != "admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"projects": [{"id": "default", "name": "Default", "owners": [{"ownerType": "user", "name": "Alice"}, {"ownerType": "system"}]}]}`))
+	})
+
+	mux.HandleFunc("GET /api/admin/search/features", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		features := ""
+
+		for i := offset; i < min(offset+limit, count); i++ {
+			if features != "" {
+				features += ","
+			}
+
+			features += fmt.Sprintf(`{
+				"name": "flag-%d",
+				"project": "default",
+				"tags": [{"type": "simple", "value": "checkout"}],
+				"links": [{"url": "https://docs.example.com/flag-%d", "title": "Docs"}],
+				"environments": [{"name": "development", "enabled": true}, {"name": "production", "enabled": false}],
+				"lifecycle": {"stage": "live"},
+				"createdBy": {"name": "Bob"}
+			}`, i, i)
+		}
+
+		fmt.Fprintf(w, `{"features": [%s], "total": %d}`, features, count)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestFetchMetadata(t *testing.T) {
+	var requests atomic.Int32
+	ts := newFakeAdminApi(t, adminSearchPageSize+1, &requests)
+
+	metadata, err := fetchMetadata(httpclient.New(nil, 0), ts.URL, "admin-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metadata) != adminSearchPageSize+1 {
+		t.Fatalf("Expected the flags of all pages, got %d", len(metadata))
+	}
+
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected the projects and two search pages, got %d requests", n)
+	}
+
+	m := metadata["flag-0"]
+
+	if m.ProjectName != "Default" || m.Stage != "live" {
+		t.Errorf("Expected the project and stage, got %+v", m)
+	}
+	if !slices.Equal(m.Owners, []string{"Alice", "Bob"}) {
+		t.Errorf("Expected the project owner and creator as owners, got %v", m.Owners)
+	}
+	if len(m.Links) != 1 || m.Links[0].Url != "https://docs.example.com/flag-0" || m.Links[0].Label() != "Docs" {
+		t.Errorf("Expected the docs link, got %+v", m.Links)
+	}
+	if len(m.Environments) != 2 || !m.Environments[0].Enabled || m.Environments[1].Enabled {
+		t.Errorf("Expected the environments, got %+v", m.Environments)
+	}
+}
+
+func TestFetchMetadataRejectsInvalidToken(t *testing.T) {
+	var requests atomic.Int32
+	ts := newFakeAdminApi(t, 1, &requests)
+
+	if _, err := fetchMetadata(httpclient.New(nil, 0), ts.URL, "wrong"); err == nil {
+		t.Fatal("Expected an error for an invalid admin token")
+	}
+}
+
+func TestLoadMetadata(t *testing.T) {
+	var requests atomic.Int32
+	ts := newFakeAdminApi(t, 2, &requests)
+
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("flag-0", "flag-1"))
+	o.Config.AdminURL = ts.URL
+	o.Config.AdminToken = "admin-token"
+	env := o.ActiveFeatureEnvironment()
+
+	if _, ok := env.Metadata("flag-0"); ok {
+		t.Fatal("Expected no metadata before loading")
+	}
+
+	o.loadMetadata()
+
+	m, ok := env.Metadata("flag-0")
+	if !ok || !m.HasTag("simple:checkout") || !m.HasTag("checkout") || m.HasTag("other") {
+		t.Fatalf("Expected the checkout tag, got %+v", m)
+	}
+
+	if tags := env.Tags(); !slices.Equal(tags, []string{"simple:checkout"}) {
+		t.Errorf("Expected the tags of the environment, got %v", tags)
+	}
+
+	// A failing admin API keeps the last loaded metadata.
+	o.Config.AdminToken = "wrong"
+	o.loadMetadata()
+
+	if _, ok := env.Metadata("flag-1"); !ok {
+		t.Error("Expected the metadata to be kept when the admin API fails")
+	}
+}
+
+func TestFeatureMetadataMatches(t *testing.T) {
+	m := FeatureMetadata{
+		Project:     "checkout",
+		ProjectName: "Checkout",
+		Tags:        []FeatureTag{{Type: "team", Value: "payments"}},
+		Owners:      []string{"Alice"},
+		Stage:       "live",
+	}
+
+	tests := []struct {
+		term string
+		want bool
+	}{
+		{term: "payments", want: true},
+		{term: "team:pay", want: true},
+		{term: "alice", want: true},
+		{term: "Checkout", want: true},
+		{term: "billing", want: false},
+		{term: " ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			if got := m.Matches(tt.term); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
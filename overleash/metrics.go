@@ -61,13 +61,79 @@ func (o *OverleashContext) sendMetrics() {
 	}
 
 	log.Debug("Sending metrics")
-	err := o.client.bulkMetrics(o.ActiveFeatureEnvironment().token, o.metrics.clientData, o.metrics.metrics)
 
-	if err != nil {
-		log.Errorf("Failed to send metrics to upstream: %v", err)
+	failedMetrics := make([]*MetricsData, 0)
+	failedClientData := make([]*ClientData, 0)
 
-		return
+	for _, batch := range o.metricBatches() {
+		err := batch.fe.client.bulkMetrics(batch.fe.token, batch.clientData, batch.metrics)
+
+		if err != nil {
+			log.Errorf("Failed to send metrics to upstream %s: %v", batch.fe.Upstream(), err)
+
+			// Kept to be sent again with the next batch.
+			failedMetrics = append(failedMetrics, batch.metrics...)
+			failedClientData = append(failedClientData, batch.clientData...)
+		}
 	}
 
 	o.metrics.reset()
+	o.metrics.metrics = append(o.metrics.metrics, failedMetrics...)
+	o.metrics.clientData = append(o.metrics.clientData, failedClientData...)
+}
+
+// metricBatch is the metrics and registrations sent to the upstream of one
+// remote.
+type metricBatch struct {
+	fe         *FeatureEnvironment
+	metrics    []*MetricsData
+	clientData []*ClientData
+}
+
+// metricBatches groups the collected metrics by the remote of their
+// environment, so they are sent to the upstream that serves it. Metrics of an
+// unknown environment go to the active remote.
+func (o *OverleashContext) metricBatches() []*metricBatch {
+	batches := make([]*metricBatch, 0, len(o.featureEnvironments))
+	byEnvironment := make(map[string]*metricBatch)
+
+	batchFor := func(environment string) *metricBatch {
+		if batch, ok := byEnvironment[environment]; ok {
+			return batch
+		}
+
+		fe := o.ActiveFeatureEnvironment()
+
+		for _, candidate := range o.featureEnvironments {
+			if candidate.environment == environment {
+				fe = candidate
+				break
+			}
+		}
+
+		for _, batch := range batches {
+			if batch.fe == fe {
+				byEnvironment[environment] = batch
+				return batch
+			}
+		}
+
+		batch := &metricBatch{fe: fe, metrics: make([]*MetricsData, 0), clientData: make([]*ClientData, 0)}
+		batches = append(batches, batch)
+		byEnvironment[environment] = batch
+
+		return batch
+	}
+
+	for _, m := range o.metrics.metrics {
+		batch := batchFor(m.Environment)
+		batch.metrics = append(batch.metrics, m)
+	}
+
+	for _, c := range o.metrics.clientData {
+		batch := batchFor(c.Environment)
+		batch.clientData = append(batch.clientData, c)
+	}
+
+	return batches
 }
@@ -8,15 +8,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/internal/httpclient"
 	"github.com/Iandenh/overleash/internal/storage"
 	"github.com/Iandenh/overleash/unleashengine"
 	"github.com/charmbracelet/log"
-	"github.com/launchdarkly/eventsource"
 )
 
 var forceEnable = Strategy{
@@ -37,21 +41,55 @@ type OverleashContext struct {
 	paused              bool
 	ticker              ticker
 	store               storage.Store
-	client              client
 	reload              time.Duration
 	metrics             *metrics
 }
 
 type FeatureEnvironment struct {
-	name              string
-	environment       string
-	token             string
+	name        string
+	environment string
+	token       string
+	upstreams   *upstreamPool
+	// httpClient sends the requests to the upstreams, with the headers of
+	// the remote.
+	httpClient *http.Client
+	// delta is set when the remote is kept up to date through the upstream
+	// delta stream instead of polling.
+	delta bool
+	// deltaPolling is set when the remote is polled through the upstream
+	// delta API instead of fetching all flags.
+	deltaPolling bool
+	// stream tracks the health of the delta stream, nil when not in delta
+	// mode.
+	stream *streamHealth
+	client client
+	// fixture is the feature file the remote is read from in offline mode.
+	fixture           string
 	featureFile       FeatureFile
 	cachedFeatureFile FeatureFile
 	cachedJson        []byte
+	compressedJson    map[string][]byte
 	etagOfCachedJson  string
-	engine            unleashengine.Engine
-	Streamer          *Streamer
+	// upstreamEtag is the ETag the upstream sent with featureFile, or the
+	// revision of the last applied delta in delta polling mode.
+	upstreamEtag string
+	breaker      upstreamBreaker
+	sync         syncTracker
+	// staleAfter is how long the environment may go without syncing before
+	// it is stale; 0 disables it.
+	staleAfter time.Duration
+	// tokenProjects are the projects of the multi-project tokens validated
+	// upstream, keyed by token.
+	tokenProjects sync.Map
+	// metadata is the admin API metadata of the flags, keyed by name.
+	metadata atomic.Pointer[map[string]FeatureMetadata]
+	engine   unleashengine.Engine
+	// engineErr is the error of the last rejected engine state update, nil
+	// when the engine took the last state.
+	engineErr   atomic.Pointer[error]
+	version     uint64
+	evaluations evaluationCache
+	Streamer    *Streamer
 }
 
 func (o *OverleashContext) ActiveFeatureEnvironment() *FeatureEnvironment {
@@ -74,6 +112,22 @@ func (fe *FeatureEnvironment) Engine() unleashengine.Engine {
 	return fe.engine
 }
 
+// EngineError returns the error of the last rejected engine state update, or
+// nil when the engine serves the compiled feature file.
+func (fe *FeatureEnvironment) EngineError() error {
+	if err := fe.engineErr.Load(); err != nil {
+		return *err
+	}
+
+	return nil
+}
+
+// Fixture returns the feature file the remote is read from in offline mode,
+// or "" when it has an upstream.
+func (fe *FeatureEnvironment) Fixture() string {
+	return fe.fixture
+}
+
 func (fe *FeatureEnvironment) Name() string {
 	return fe.name
 }
@@ -82,6 +136,24 @@ func (fe *FeatureEnvironment) Environment() string {
 	return fe.environment
 }
 
+// Upstream returns the URL of the Unleash instance currently serving the
+// remote.
+func (fe *FeatureEnvironment) Upstream() string {
+	return fe.upstreams.serving()
+}
+
+// Upstreams returns the URLs the remote is loaded from, in order of preference.
+func (fe *FeatureEnvironment) Upstreams() []string {
+	return fe.upstreams.all()
+}
+
+// UpstreamHTTPClient returns the client used for requests to the upstream of
+// the remote, configured with the upstream TLS, proxy, timeout and header
+// settings.
+func (fe *FeatureEnvironment) UpstreamHTTPClient() *http.Client {
+	return fe.httpClient
+}
+
 type OverrideConstraint struct {
 	Enabled    bool       `json:"enabled"`
 	Constraint Constraint `json:"constraint"`
@@ -110,10 +182,29 @@ func NewOverleash(cfg *config.Config) *OverleashContext {
 }
 
 func makeFeatureEnvironments(cfg *config.Config) []*FeatureEnvironment {
-	tokens := cfg.Tokens()
-	features := make([]*FeatureEnvironment, len(tokens))
+	remotes, err := cfg.RemoteConfigs()
+
+	if err != nil {
+		log.Fatalf("invalid remotes: %v", err)
+	}
+
+	fixtures, err := cfg.OfflineFixtures()
+
+	if err != nil {
+		log.Fatalf("invalid offline fixtures: %v", err)
+	}
+
+	if fixtures != nil {
+		remotes = offlineRemotes(fixtures)
+	}
+
+	transport := httpclient.NewTransportFromConfig(cfg)
+	headers := httpclient.HeadersFromConfig(cfg)
+	features := make([]*FeatureEnvironment, len(remotes))
+
+	for i, remote := range remotes {
+		token := remote.Token
 
-	for i, token := range tokens {
 		env, err := ExtractEnvironment(token)
 		if err != nil {
 			env = "default"
@@ -122,12 +213,14 @@ func makeFeatureEnvironments(cfg *config.Config) []*FeatureEnvironment {
 		var s *Streamer
 		var e unleashengine.Engine
 
-		if cfg.Streamer {
+		// The dashboard and the frontend stream are kept up to date through
+		// the streamer as well.
+		if cfg.Streamer || !cfg.Headless || cfg.EnableFrontend {
 			s = NewStreamer()
 		}
 
 		if cfg.EnableFrontend {
-			e = unleashengine.NewUnleashEngine()
+			e = newEngine()
 		}
 
 		parts := strings.SplitN(token, ".", 2)
@@ -137,11 +230,25 @@ func makeFeatureEnvironments(cfg *config.Config) []*FeatureEnvironment {
 		}
 
 		features[i] = &FeatureEnvironment{
-			name:        name,
-			token:       token,
-			engine:      e,
-			Streamer:    s,
-			environment: env,
+			name:         name,
+			token:        token,
+			upstreams:    newUpstreamPool(remote.Upstreams...),
+			httpClient:   httpclient.New(httpclient.WithHeaders(transport, headers, httpclient.NewHeader(remote.Headers)), cfg.UpstreamTimeout),
+			delta:        remote.Delta,
+			deltaPolling: remote.DeltaPolling,
+			engine:       e,
+			Streamer:     s,
+			environment:  env,
+			sync:         syncTracker{started: time.Now()},
+			staleAfter:   cfg.StaleAfter,
+		}
+
+		if remote.Delta {
+			features[i].stream = newStreamHealth(time.Now())
+		}
+
+		if fixtures != nil {
+			features[i].fixture = fixtures[i].Path
 		}
 	}
 
@@ -149,8 +256,12 @@ func makeFeatureEnvironments(cfg *config.Config) []*FeatureEnvironment {
 }
 
 func (o *OverleashContext) Start(ctx context.Context) {
-	if o.client == nil {
-		o.client = newClient(o.Upstream(), o.Config.ParseReload(), ctx)
+	for _, fe := range o.featureEnvironments {
+		if fe.client == nil && fe.fixture != "" {
+			fe.client = newFixtureClient(fe.fixture)
+		} else if fe.client == nil {
+			fe.client = newClient(fe.upstreams, o.Config.ParseReload(), fe.httpClient, ctx)
+		}
 	}
 
 	if overrides, err := o.readOverrides(); err == nil {
@@ -173,17 +284,23 @@ func (o *OverleashContext) Start(ctx context.Context) {
 		o.registerRemotes()
 	}
 
-	if o.Config.Delta {
-		o.startStreamListeners(ctx)
+	if o.Config.AdminToken != "" {
+		o.startMetadataFetcher(ctx)
+	}
+
+	o.startStreamListeners(ctx)
 
+	polling := o.pollingRemotes()
+
+	if len(polling) == 0 {
 		return
 	}
 
-	err := o.loadRemotesWithLock()
+	err := o.loadPollingRemotesWithLock()
 
 	if err != nil {
-		if o.Config.Backup {
-			for idx, feature := range o.featureEnvironments {
+		if o.backupEnabled() {
+			for _, feature := range polling {
 				data, err := o.store.Read(feature.name + "-backup.json")
 
 				if err != nil {
@@ -198,7 +315,8 @@ func (o *OverleashContext) Start(ctx context.Context) {
 					panic(err)
 				}
 
-				o.featureEnvironments[idx].featureFile = f
+				feature.featureFile = f
+				feature.sync.restore()
 			}
 			o.compileFeatureFiles()
 
@@ -208,6 +326,12 @@ func (o *OverleashContext) Start(ctx context.Context) {
 		}
 	}
 
+	if o.Config.Offline != "" && o.Config.OfflineWatch {
+		if err := o.watchFixtures(ctx); err != nil {
+			log.Errorf("Unable to watch offline fixtures: %v", err)
+		}
+	}
+
 	if o.reload == 0 {
 		log.Info("Start without reloading")
 		return
@@ -231,10 +355,12 @@ func (o *OverleashContext) registerEventStore(ctx context.Context, store storage
 				return
 			}
 
+			previous := o.overrides
 			o.overrides = *overrides
 
 			log.Debug("Overrides loaded from store")
 			o.compileFeatureFiles()
+			o.processOverleashStreaming(overridesChangedEvents(previous, o.overrides))
 		} else if key == "paused.json" {
 			o.LockMutex.Lock()
 			defer o.LockMutex.Unlock()
@@ -245,9 +371,14 @@ func (o *OverleashContext) registerEventStore(ctx context.Context, store storage
 				return
 			}
 
+			changed := o.paused != paused
 			o.paused = paused
 			log.Debug("Paused loaded from store")
 			o.compileFeatureFiles()
+
+			if changed {
+				o.processOverleashStreaming(pausedChangedEvents(paused))
+			}
 		} else if key == "webhook-received" {
 			err := o.RefreshFeatureFiles()
 
@@ -275,90 +406,198 @@ func (o *OverleashContext) startFetcher(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-o.ticker.ticker.C:
-				o.loadRemotesWithLock()
+				o.loadPollingRemotesWithLock()
 			}
 		}
 	}()
 }
 
+// startStreamListeners subscribes to the upstream delta stream of every remote
+// in delta mode.
 func (o *OverleashContext) startStreamListeners(ctx context.Context) {
-	o.ticker = createTicker(o.reload)
-
-	log.Infof("Start with streaming")
-
 	for idx, f := range o.FeatureEnvironments() {
-		channel := make(chan eventsource.Event)
+		if !f.delta {
+			continue
+		}
 
-		o.client.streamFeatures(f.token, channel)
+		log.Infof("Start streaming %s from %s", f.environment, strings.Join(f.Upstreams(), ", "))
 
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case event := <-channel:
-					f.processSseEvent(event, o, idx == 0)
-				}
-			}
-		}()
+		go o.listenToStream(ctx, idx, f)
 	}
 }
 
 func (o *OverleashContext) registerRemotes() {
 	for _, featureEnvironment := range o.featureEnvironments {
 		if token, ok := fromString(featureEnvironment.token); ok == true {
-			o.client.registerClient(token)
+			featureEnvironment.client.registerClient(token)
 		}
 	}
 }
 
+// loadRemotesWithLock fetches every remote, including those in delta mode,
+// e.g. after a webhook.
 func (o *OverleashContext) loadRemotesWithLock() error {
 	o.LockMutex.Lock()
 	defer o.LockMutex.Unlock()
 
-	return o.loadRemotes()
+	return o.loadRemotes(o.featureEnvironments, true)
+}
+
+// loadPollingRemotesWithLock fetches the remotes that are not in delta mode.
+func (o *OverleashContext) loadPollingRemotesWithLock() error {
+	o.LockMutex.Lock()
+	defer o.LockMutex.Unlock()
+
+	return o.loadRemotes(o.pollingRemotes(), false)
+}
+
+func (o *OverleashContext) pollingRemotes() []*FeatureEnvironment {
+	polling := make([]*FeatureEnvironment, 0, len(o.featureEnvironments))
+
+	for _, fe := range o.featureEnvironments {
+		if !fe.delta {
+			polling = append(polling, fe)
+		}
+	}
+
+	return polling
 }
 
 func (o *OverleashContext) LoadFeatureFile(state FeatureFile) {
 	o.ActiveFeatureEnvironment().featureFile = state
+	o.ActiveFeatureEnvironment().upstreamEtag = ""
 	o.compileFeatureFiles()
 }
 
-func (o *OverleashContext) loadRemotes() error {
+// loadRemotes fetches remotes. An explicit fetch, e.g. after a webhook or a
+// manual refresh, does not wait for the backoff of a failing upstream but
+// probes it.
+func (o *OverleashContext) loadRemotes(remotes []*FeatureEnvironment, explicit bool) error {
 	e := error(nil)
 
 	hasRefreshed := false
-	for idx, featureEnvironment := range o.featureEnvironments {
-		featureFile, err := o.client.getFeatures(featureEnvironment.token)
+	hasSynced := false
+	statusChanged := false
+	for _, featureEnvironment := range remotes {
+		now := time.Now()
+
+		if explicit {
+			featureEnvironment.breaker.probe()
+		} else if !featureEnvironment.breaker.allow(now) {
+			log.Debugf("Skipping upstream of %s until %s", featureEnvironment.environment, featureEnvironment.UpstreamStatus().RetryAt.Format(time.TimeOnly))
+			continue
+		}
 
-		if err != nil {
+		var featureFile *FeatureFile
+		var events *Events
+		var etag string
+		var err error
+
+		featureEnvironment.sync.attempt(now)
+
+		if featureEnvironment.deltaPolling {
+			events, etag, err = featureEnvironment.client.getDelta(featureEnvironment.token, featureEnvironment.upstreamEtag)
+		} else {
+			featureFile, etag, err = featureEnvironment.client.getFeatures(featureEnvironment.token, featureEnvironment.upstreamEtag)
+		}
+
+		// Unleash sends no Overleash events, and applying compiles the
+		// environment already.
+		if err == nil && events != nil && !featureEnvironment.applyEvents(*events, o, false) {
+			// The revision is cleared so the next poll hydrates, instead of
+			// moving past events that were never applied.
+			featureEnvironment.upstreamEtag = ""
+			err = fmt.Errorf("unable to apply the delta of %s from upstream", featureEnvironment.environment)
+		}
+
+		if err != nil && !errors.Is(err, errNotModified) {
 			log.Errorf("Error loading features: %s", err.Error())
 			e = errors.Join(e, err)
+			featureEnvironment.sync.failure(err, now)
+
+			if featureEnvironment.breaker.failure(err, now, o.reload) {
+				statusChanged = true
+				o.logUpstreamStatus(featureEnvironment)
+			}
+
+			continue
+		}
+
+		if featureEnvironment.breaker.success() {
+			statusChanged = true
+			log.Infof("Upstream of %s recovered", featureEnvironment.environment)
+		}
+
+		featureEnvironment.sync.success(now, etag)
+
+		if errors.Is(err, errNotModified) {
+			hasSynced = true
 			continue
 		}
 
-		o.featureEnvironments[idx].featureFile = *featureFile
-		hasRefreshed = true
+		if events != nil {
+			featureFile = &featureEnvironment.featureFile
+		} else {
+			featureEnvironment.featureFile = *featureFile
+			hasRefreshed = true
+		}
+
+		featureEnvironment.upstreamEtag = etag
+		hasSynced = true
 
-		if o.Config.Backup {
+		if o.backupEnabled() {
 			data, err := json.Marshal(featureFile)
 
 			if err != nil {
 				continue
 			}
 
-			o.store.Write(o.featureEnvironments[idx].name+"-backup.json", data)
+			o.store.Write(featureEnvironment.name+"-backup.json", data)
 		}
 	}
 
 	if hasRefreshed {
 		o.compileFeatureFiles()
+	}
+
+	if hasSynced {
 		o.lastSync = time.Now()
 	}
 
+	// The sync event also refreshes the upstream status on the dashboard.
+	if hasSynced || statusChanged {
+		o.processOverleashStreaming(syncedEvents(o.lastSync))
+	}
+
 	return e
 }
 
+// PingStorage reports whether the storage backend is reachable. Backends that
+// cannot become unavailable always are.
+func (o *OverleashContext) PingStorage(ctx context.Context) error {
+	if ps, ok := o.store.(storage.PingStore); ok {
+		return ps.Ping(ctx)
+	}
+
+	return nil
+}
+
+// backupEnabled reports whether feature files are backed up to the store. The
+// fixtures of offline mode are not, so they never replace a real backup.
+func (o *OverleashContext) backupEnabled() bool {
+	return o.Config.Backup && o.Config.Offline == ""
+}
+
+func (o *OverleashContext) logUpstreamStatus(fe *FeatureEnvironment) {
+	status := fe.UpstreamStatus()
+
+	if status.State == CircuitOpen {
+		log.Warnf("Upstream of %s failed %d times, circuit open until %s", fe.environment, status.Failures, status.RetryAt.Format(time.TimeOnly))
+	} else {
+		log.Warnf("Upstream of %s failed, retrying at %s", fe.environment, status.RetryAt.Format(time.TimeOnly))
+	}
+}
+
 func (o *OverleashContext) ProcessWebhook() error {
 	err := o.RefreshFeatureFiles()
 
@@ -411,7 +650,7 @@ func (o *OverleashContext) AddOverride(featureFlag string, enabled bool) {
 
 	o.compileFeatureFiles()
 	o.writeOverrides(o.overrides)
-	go o.processOverleashStreaming()
+	o.processOverleashStreaming(overrideUpdatedEvents(*o.overrides[featureFlag]))
 }
 
 func (o *OverleashContext) AddOverrideConstraint(featureFlag string, enabled bool, constraint Constraint) {
@@ -434,7 +673,7 @@ func (o *OverleashContext) AddOverrideConstraint(featureFlag string, enabled boo
 
 	o.compileFeatureFiles()
 	o.writeOverrides(o.overrides)
-	go o.processOverleashStreaming()
+	o.processOverleashStreaming(overrideUpdatedEvents(*o.overrides[featureFlag]))
 }
 
 func (o *OverleashContext) DeleteOverride(featureFlag string) {
@@ -445,18 +684,19 @@ func (o *OverleashContext) DeleteOverride(featureFlag string) {
 
 	o.compileFeatureFiles()
 	o.writeOverrides(o.overrides)
-	go o.processOverleashStreaming()
+	o.processOverleashStreaming(overrideRemovedEvents(featureFlag))
 }
 
 func (o *OverleashContext) DeleteAllOverride() {
 	o.LockMutex.Lock()
 	defer o.LockMutex.Unlock()
 
+	removed := slices.Sorted(maps.Keys(o.overrides))
 	o.overrides = make(map[string]*Override)
 
 	o.compileFeatureFiles()
 	o.writeOverrides(o.overrides)
-	go o.processOverleashStreaming()
+	o.processOverleashStreaming(overrideRemovedEvents(removed...))
 }
 
 func (o *OverleashContext) SetPaused(paused bool) {
@@ -467,7 +707,7 @@ func (o *OverleashContext) SetPaused(paused bool) {
 
 	o.compileFeatureFiles()
 	o.writePaused(paused)
-	go o.processOverleashStreaming()
+	o.processOverleashStreaming(pausedChangedEvents(paused))
 }
 
 func (o *OverleashContext) IsPaused() bool {
@@ -527,7 +767,10 @@ func (fe *FeatureEnvironment) compile(o *OverleashContext) {
 	df := fe.featureFileWithOverwrites(o)
 
 	if fe.Streamer != nil {
-		go fe.Streamer.processFeature(fe.cachedFeatureFile, df, fe.featureFile)
+		s, old, remote := fe.Streamer, fe.cachedFeatureFile, fe.featureFile
+		s.enqueue(func() {
+			s.processFeature(old, df, remote)
+		})
 	}
 
 	fe.cachedFeatureFile = df
@@ -543,6 +786,7 @@ func (fe *FeatureEnvironment) compile(o *OverleashContext) {
 	}
 
 	fe.cachedJson = buf.Bytes()
+	fe.compressedJson = compressJson(fe.cachedJson)
 
 	fe.etagOfCachedJson = calculateETag(fe.cachedJson)
 
@@ -552,8 +796,14 @@ func (fe *FeatureEnvironment) compile(o *OverleashContext) {
 		// compiled feature file.
 		if err := fe.engine.TakeState(string(fe.cachedJson)); err != nil {
 			log.Errorf("Failed to update engine state for %s: %v", fe.name, err)
+			fe.engineErr.Store(&err)
+		} else {
+			fe.engineErr.Store(nil)
 		}
 	}
+
+	fe.version++
+	fe.evaluations.reset(fe.version, analyseDeterminism(df))
 }
 
 func (fe *FeatureEnvironment) featureFileWithOverwrites(o *OverleashContext) FeatureFile {
@@ -1,16 +1,22 @@
 package overleash
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/internal/httpclient"
 	"github.com/Iandenh/overleash/unleashengine"
 	"github.com/launchdarkly/eventsource"
 )
@@ -90,18 +96,29 @@ func (fc *fakeClient) registerClient(token *EdgeToken) error {
 	return nil
 }
 
-func (fc *fakeClient) getFeatures(token string) (*FeatureFile, error) {
-	return &fc.featureFile, fc.err
+func (fc *fakeClient) getFeatures(token string, etag string) (*FeatureFile, string, error) {
+	return &fc.featureFile, "", fc.err
+}
+
+func (fc *fakeClient) getDelta(token string, revision string) (*Events, string, error) {
+	return nil, "", fc.err
 }
 
 func (fc *fakeClient) bulkMetrics(token string, applications []*ClientData, metrics []*MetricsData) error {
 	return nil
 }
 
-func (fc *fakeClient) streamFeatures(token string, channel chan eventsource.Event) error {
+func (fc *fakeClient) streamFeatures(token string, channel chan eventsource.Event, errs chan<- error) error {
 	return nil
 }
 
+// useClient makes every remote of o use c.
+func useClient(o *OverleashContext, c client) {
+	for _, fe := range o.featureEnvironments {
+		fe.client = c
+	}
+}
+
 // TestCompileFeatureFile verifies that compileFeatureFiles correctly encodes
 // the remote feature file (with no overrides) and updates the cached JSON, ETag,
 // and engine state.
@@ -444,7 +461,7 @@ func TestLoadRemotes(t *testing.T) {
 	}
 	// Use a fakeClient.
 	fc := &fakeClient{featureFile: ff, err: nil}
-	o.client = fc
+	useClient(o, fc)
 
 	// Call loadRemotesWithLock.
 	if err := o.loadRemotesWithLock(); err != nil {
@@ -456,6 +473,132 @@ func TestLoadRemotes(t *testing.T) {
 	}
 }
 
+// TestLoadRemotesKeepsFeaturesWhenUnchangedOrFailing verifies that a 304
+// neither replaces nor recompiles the feature file, and that an error response
+// leaves the flags in place.
+func TestLoadRemotesKeepsFeaturesWhenUnchangedOrFailing(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status.Load() == http.StatusOK && r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(`{"version":2,"features":[{"name":"featureX","enabled":true}]}`))
+	}))
+	defer ts.Close()
+
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	useClient(o, newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background()))
+	env := o.ActiveFeatureEnvironment()
+
+	if err := o.loadRemotesWithLock(); err != nil {
+		t.Fatalf("loadRemotesWithLock returned error: %v", err)
+	}
+
+	version := env.version
+
+	if len(env.featureFile.Features) != 1 || env.upstreamEtag != `"v1"` {
+		t.Fatalf("Expected one feature with etag \"v1\", got %v with etag %s", env.featureFile.Features, env.upstreamEtag)
+	}
+
+	if err := o.loadRemotesWithLock(); err != nil {
+		t.Fatalf("Expected a 304 not to be an error, got %v", err)
+	}
+	if env.version != version || len(env.featureFile.Features) != 1 {
+		t.Errorf("Expected a 304 to keep the compiled feature file, got version %d (was %d)", env.version, version)
+	}
+
+	status.Store(http.StatusInternalServerError)
+
+	err := o.loadRemotesWithLock()
+
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected an UpstreamError with status 500, got %v", err)
+	}
+	if env.version != version || len(env.featureFile.Features) != 1 {
+		t.Errorf("Expected an error response to keep the flags, got %v", env.featureFile.Features)
+	}
+}
+
+func TestLoadRemotesPollsDelta(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	useClient(o, newClient(newUpstreamPool(deltaUpstream(t).URL), 1, httpclient.New(nil, 0), context.Background()))
+	env := o.ActiveFeatureEnvironment()
+	env.deltaPolling = true
+
+	names := func() []string {
+		names := make([]string, 0)
+
+		for _, f := range env.featureFile.Features {
+			names = append(names, f.Name)
+		}
+
+		slices.Sort(names)
+
+		return names
+	}
+
+	for _, want := range [][]string{{"a", "b"}, {"a", "c"}} {
+		if err := o.loadPollingRemotesWithLock(); err != nil {
+			t.Fatalf("loadPollingRemotesWithLock returned error: %v", err)
+		}
+
+		if got := names(); !slices.Equal(got, want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	version := env.version
+
+	if err := o.loadPollingRemotesWithLock(); err != nil {
+		t.Fatalf("Expected a 304 not to be an error, got %v", err)
+	}
+	if env.version != version || env.upstreamEtag != `"3"` {
+		t.Errorf("Expected a 304 to keep the compiled feature file, got version %d (was %d)", env.version, version)
+	}
+}
+
+// rejectedDeltaClient sends a delta that cannot be applied to an environment
+// other than the main one.
+type rejectedDeltaClient struct {
+	fakeClient
+}
+
+func (c *rejectedDeltaClient) getDelta(token string, revision string) (*Events, string, error) {
+	return &Events{Events: []Event{&PausedChangedEvent{EventId: 4, Paused: true}}}, `"4"`, nil
+}
+
+func TestLoadRemotesRejectsUnappliedDelta(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("a"))
+	o.Config.Backup = true
+	useClient(o, &rejectedDeltaClient{})
+	env := o.ActiveFeatureEnvironment()
+	env.deltaPolling = true
+	env.upstreamEtag = `"3"`
+
+	if err := o.loadPollingRemotesWithLock(); err == nil {
+		t.Fatal("Expected an unapplied delta to be an error")
+	}
+
+	if env.upstreamEtag != "" || len(env.featureFile.Features) != 1 {
+		t.Errorf("Expected the revision to be cleared and the flags kept, got revision %q", env.upstreamEtag)
+	}
+
+	if status := env.SyncStatus(); !status.Failing() || !status.LastSuccess.IsZero() {
+		t.Errorf("Expected the poll to count as a failure, got %+v", status)
+	}
+
+	if _, err := o.store.Read(env.name + "-backup.json"); err == nil {
+		t.Error("Expected no backup of an unapplied delta")
+	}
+}
+
 // TestRefreshFeatureFiles verifies that RefreshFeatureFiles updates remotes and resets the ticker.
 func TestRefreshFeatureFiles(t *testing.T) {
 	cfg := &config.Config{
@@ -483,7 +626,7 @@ func TestRefreshFeatureFiles(t *testing.T) {
 		},
 	}
 	fc := &fakeClient{featureFile: ff, err: nil}
-	o.client = fc
+	useClient(o, fc)
 
 	if err := o.RefreshFeatureFiles(); err != nil {
 		t.Errorf("RefreshFeatureFiles returned error: %v", err)
@@ -559,14 +702,14 @@ func TestStartWithoutReload(t *testing.T) {
 	}
 
 	o := NewOverleash(cfg)
-	o.client = &fakeClient{}
+	useClient(o, &fakeClient{})
 
 	// For this test, set reload to 0.
 	ctx := t.Context()
 	// Calling Start with reload==0 should not start any goroutine.
 	o.Start(ctx)
-	// Simply check that no panic occurred and that overleashClient was created.
-	if o.client == nil {
-		t.Error("Expected overleashClient to be created in Start")
+	// Simply check that no panic occurred and that the remote kept its client.
+	if o.ActiveFeatureEnvironment().client == nil {
+		t.Error("Expected the remote to have a client after Start")
 	}
 }
@@ -0,0 +1,203 @@
+package overleash
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis is an in-process stand-in for Redis. It speaks just enough RESP2
+// for the RedisStore: GET, SET, PUBLISH and SUBSCRIBE.
+type fakeRedis struct {
+	listener net.Listener
+
+	mutex       sync.Mutex
+	data        map[string][]byte
+	subscribers map[string][]*fakeRedisConn
+}
+
+type fakeRedisConn struct {
+	mutex  sync.Mutex
+	writer *bufio.Writer
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeRedis{
+		listener:    listener,
+		data:        make(map[string][]byte),
+		subscribers: make(map[string][]*fakeRedisConn),
+	}
+
+	go r.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return r
+}
+
+func (r *fakeRedis) addr() string {
+	return r.listener.Addr().String()
+}
+
+func (r *fakeRedis) subscriberCount(channel string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.subscribers[channel])
+}
+
+func (r *fakeRedis) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go r.handle(conn)
+	}
+}
+
+func (r *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	c := &fakeRedisConn{writer: bufio.NewWriter(conn)}
+	subscribed := false
+
+	defer r.unsubscribe(c)
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			if subscribed {
+				c.write("*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+			} else {
+				c.write("+PONG\r\n")
+			}
+		case "SET":
+			r.mutex.Lock()
+			r.data[args[1]] = []byte(args[2])
+			r.mutex.Unlock()
+			c.write("+OK\r\n")
+		case "GET":
+			r.mutex.Lock()
+			value, ok := r.data[args[1]]
+			r.mutex.Unlock()
+
+			if ok {
+				c.write(bulk(string(value)))
+			} else {
+				c.write("$-1\r\n")
+			}
+		case "PUBLISH":
+			r.mutex.Lock()
+			subscribers := r.subscribers[args[1]]
+			r.mutex.Unlock()
+
+			for _, subscriber := range subscribers {
+				subscriber.write("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+			}
+
+			c.write(":" + strconv.Itoa(len(subscribers)) + "\r\n")
+		case "SUBSCRIBE":
+			subscribed = true
+
+			for i, channel := range args[1:] {
+				r.mutex.Lock()
+				r.subscribers[channel] = append(r.subscribers[channel], c)
+				r.mutex.Unlock()
+
+				c.write("*3\r\n" + bulk("subscribe") + bulk(channel) + ":" + strconv.Itoa(i+1) + "\r\n")
+			}
+		case "SELECT", "CLIENT":
+			c.write("+OK\r\n")
+		default:
+			c.write("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+	}
+}
+
+func (r *fakeRedis) unsubscribe(c *fakeRedisConn) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for channel, subscribers := range r.subscribers {
+		remaining := subscribers[:0]
+
+		for _, subscriber := range subscribers {
+			if subscriber != c {
+				remaining = append(remaining, subscriber)
+			}
+		}
+
+		r.subscribers[channel] = remaining
+	}
+}
+
+func (c *fakeRedisConn) write(reply string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writer.WriteString(reply)
+	c.writer.Flush()
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
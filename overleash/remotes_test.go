@@ -0,0 +1,214 @@
+package overleash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+)
+
+func TestRemotesUseTheirOwnUpstream(t *testing.T) {
+	upstream := func(feature string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"version":2,"features":[{"name":"` + feature + `"}]}`))
+		}))
+	}
+
+	staging := upstream("staging-flag")
+	defer staging.Close()
+
+	production := upstream("production-flag")
+	defer production.Close()
+
+	cfg := &config.Config{
+		Upstream: staging.URL,
+		Remotes:  `[{"token": "*:staging.abc"}, {"token": "*:production.abc", "upstream": "` + production.URL + `"}]`,
+		Storage:  "null",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.Start(t.Context())
+
+	for _, tt := range []struct {
+		env      string
+		upstream string
+		feature  string
+	}{
+		{env: "staging", upstream: staging.URL, feature: "staging-flag"},
+		{env: "production", upstream: production.URL, feature: "production-flag"},
+	} {
+		idx := indexOfEnvironment(o, tt.env)
+		fe := o.FeatureEnvironments()[idx]
+
+		if fe.Upstream() != tt.upstream {
+			t.Errorf("Expected %s to use %s, got %s", tt.env, tt.upstream, fe.Upstream())
+		}
+
+		if features := fe.featureFile.Features; len(features) != 1 || features[0].Name != tt.feature {
+			t.Errorf("Expected %s to have %s, got %v", tt.env, tt.feature, features)
+		}
+	}
+}
+
+func TestRemotesSendTheirOwnHeaders(t *testing.T) {
+	var mutex sync.Mutex
+	tenants := make(map[string]string)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		tenants[r.Header.Get("Authorization")] = r.Header.Get("X-Tenant") + "/" + r.Header.Get("X-Service-Token")
+		mutex.Unlock()
+
+		w.Write([]byte(`{"version":2,"features":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Upstream:        upstream.URL,
+		UpstreamHeaders: `{"*": {"X-Service-Token": "shared", "X-Tenant": "none"}}`,
+		Remotes:         `[{"token": "*:production.eu", "headers": {"X-Tenant": "eu"}}, {"token": "*:production.us", "headers": {"X-Tenant": "us"}}, {"token": "*:production.other"}]`,
+		Storage:         "null",
+		Reload:          "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.Start(t.Context())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for token, want := range map[string]string{"*:production.eu": "eu/shared", "*:production.us": "us/shared", "*:production.other": "none/shared"} {
+		if tenants[token] != want {
+			t.Errorf("Expected %s to send %s, got %q", token, want, tenants[token])
+		}
+	}
+}
+
+func indexOfEnvironment(o *OverleashContext, env string) int {
+	for idx, fe := range o.FeatureEnvironments() {
+		if fe.Environment() == env {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+func TestRemotesInDeltaModeAreNotPolled(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Remotes:  `[{"token": "*:staging.abc"}, {"token": "*:production.abc", "delta": true}]`,
+		Storage:  "null",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+
+	polling := o.pollingRemotes()
+
+	if len(polling) != 1 || polling[0].Environment() != "staging" {
+		t.Errorf("Expected only staging to be polled, got %v", polling)
+	}
+}
+
+func TestRemoteSyncModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		delta        bool
+		deltaPolling bool
+		remote       string
+		wantDelta    bool
+		wantPolling  bool
+		wantErr      bool
+	}{
+		{name: "full polling", remote: `{"token": "*:dev.abc"}`},
+		{name: "global delta polling", deltaPolling: true, remote: `{"token": "*:dev.abc"}`, wantPolling: true},
+		{name: "remote delta polling", remote: `{"token": "*:dev.abc", "deltaPolling": true}`, wantPolling: true},
+		{name: "remote streaming overrides global polling", deltaPolling: true, remote: `{"token": "*:dev.abc", "delta": true}`, wantDelta: true},
+		{name: "remote polling overrides global streaming", delta: true, remote: `{"token": "*:dev.abc", "deltaPolling": true}`, wantPolling: true},
+		{name: "both on a remote", remote: `{"token": "*:dev.abc", "delta": true, "deltaPolling": true}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Delta: tt.delta, DeltaPolling: tt.deltaPolling, Remotes: "[" + tt.remote + "]"}
+
+			remotes, err := cfg.RemoteConfigs()
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if remotes[0].Delta != tt.wantDelta || remotes[0].DeltaPolling != tt.wantPolling {
+				t.Errorf("Expected delta %v and delta polling %v, got %+v", tt.wantDelta, tt.wantPolling, remotes[0])
+			}
+		})
+	}
+}
+
+// recordingClient records which tokens metrics were sent for.
+type recordingClient struct {
+	fakeClient
+
+	mutex   sync.Mutex
+	metrics map[string][]string
+}
+
+func (rc *recordingClient) bulkMetrics(token string, applications []*ClientData, metrics []*MetricsData) error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	for _, m := range metrics {
+		rc.metrics[token] = append(rc.metrics[token], m.AppName)
+	}
+
+	return nil
+}
+
+func TestMetricsAreSentToTheRemoteOfTheirEnvironment(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Token:    "*:staging.abc,*:production.abc",
+		Storage:  "null",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.metrics = &metrics{}
+
+	staging := &recordingClient{metrics: make(map[string][]string)}
+	production := &recordingClient{metrics: make(map[string][]string)}
+	o.featureEnvironments[0].client = staging
+	o.featureEnvironments[1].client = production
+
+	o.metrics.metrics = []*MetricsData{
+		{Environment: "production", AppName: "web"},
+		{Environment: "staging", AppName: "api"},
+		{Environment: "unknown", AppName: "batch"},
+	}
+
+	o.sendMetrics()
+
+	if got := strings.Join(staging.metrics["*:staging.abc"], ","); got != "api,batch" {
+		t.Errorf("Expected staging to receive api and batch, got %q", got)
+	}
+
+	if got := strings.Join(production.metrics["*:production.abc"], ","); got != "web" {
+		t.Errorf("Expected production to receive web, got %q", got)
+	}
+
+	if len(o.metrics.metrics) != 0 {
+		t.Errorf("Expected the sent metrics to be cleared, got %v", o.metrics.metrics)
+	}
+}
@@ -0,0 +1,194 @@
+package overleash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Iandenh/overleash/unleashengine"
+)
+
+// RequestOverrideHeader carries overrides that only apply to the request they
+// are sent with, e.g. "flagA=on,flagB=off,flagC=variant:blue".
+const RequestOverrideHeader = "X-Overleash-Override"
+
+var newEngine = func() unleashengine.Engine {
+	return unleashengine.NewUnleashEngine()
+}
+
+type RequestOverride struct {
+	FeatureFlag string
+	Enabled     bool
+	Variant     string
+}
+
+// ParseRequestOverrides parses the value of the X-Overleash-Override header.
+//
+// Each entry is a flag name followed by "on", "off" or "variant:<name>". A
+// variant implies the flag is on.
+func ParseRequestOverrides(header string) ([]RequestOverride, error) {
+	overrides := make([]RequestOverride, 0)
+
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid override %q: expected flag=value", entry)
+		}
+
+		override := RequestOverride{FeatureFlag: name}
+
+		switch {
+		case value == "on" || value == "true":
+			override.Enabled = true
+		case value == "off" || value == "false":
+			override.Enabled = false
+		case strings.HasPrefix(value, "variant:"):
+			override.Enabled = true
+			override.Variant = strings.TrimSpace(strings.TrimPrefix(value, "variant:"))
+
+			if override.Variant == "" {
+				return nil, fmt.Errorf("invalid override %q: missing variant name", entry)
+			}
+		default:
+			return nil, fmt.Errorf("invalid override %q: unknown value %q", entry, value)
+		}
+
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
+
+// RequestState is a compiled feature file with request overrides applied on top
+// of the environment's state. It is never stored and lives for one request only.
+type RequestState struct {
+	featureFile FeatureFile
+	json        []byte
+	etag        string
+	engine      unleashengine.Engine
+}
+
+// WithRequestOverrides compiles a temporary state for the environment with the
+// given overrides applied on top of the persisted ones. The caller must Close
+// the returned state.
+func (fe *FeatureEnvironment) WithRequestOverrides(overrides []RequestOverride) (*RequestState, error) {
+	featureFile := fe.cachedFeatureFile
+
+	f := make(FeatureFlags, len(featureFile.Features))
+	copy(f, featureFile.Features)
+	featureFile.Features = f
+
+	for _, override := range overrides {
+		for idx, flag := range featureFile.Features {
+			if flag.Name != override.FeatureFlag {
+				continue
+			}
+
+			featureFile.Features[idx].Enabled = override.Enabled
+
+			if override.Enabled {
+				featureFile.Features[idx].Strategies = requestOverrideStrategies(override, flag)
+			}
+
+			break
+		}
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := json.NewEncoder(buf).Encode(featureFile); err != nil {
+		return nil, err
+	}
+
+	return &RequestState{
+		featureFile: featureFile,
+		json:        buf.Bytes(),
+		etag:        calculateETag(buf.Bytes()),
+	}, nil
+}
+
+func requestOverrideStrategies(override RequestOverride, feature Feature) []Strategy {
+	if override.Variant == "" {
+		return []Strategy{forceEnable}
+	}
+
+	variant := StrategyVariant{
+		Name:       override.Variant,
+		Weight:     1000,
+		Stickiness: "default",
+		Payload:    Payload{},
+	}
+
+	for _, v := range feature.Variants {
+		if v.Name == override.Variant {
+			variant.Payload = v.Payload
+			break
+		}
+	}
+
+	for _, strategy := range feature.Strategies {
+		for _, v := range strategy.Variants {
+			if v.Name == override.Variant {
+				variant.Payload = v.Payload
+				break
+			}
+		}
+	}
+
+	strategy := forceEnable
+	strategy.Variants = []StrategyVariant{variant}
+
+	return []Strategy{strategy}
+}
+
+func (s *RequestState) FeatureFile() FeatureFile {
+	return s.featureFile
+}
+
+func (s *RequestState) CachedJson() []byte {
+	return s.json
+}
+
+func (s *RequestState) EtagOfCachedJson() string {
+	return s.etag
+}
+
+// Engine returns an engine loaded with the request state. The engine is only
+// created on first use, as the client API does not need one.
+func (s *RequestState) Engine() (unleashengine.Engine, error) {
+	if s.engine != nil {
+		return s.engine, nil
+	}
+
+	engine := newEngine()
+
+	if err := engine.TakeState(string(s.json)); err != nil {
+		if closer, ok := engine.(io.Closer); ok {
+			closer.Close()
+		}
+
+		return nil, fmt.Errorf("failed to compile request overrides: %w", err)
+	}
+
+	s.engine = engine
+
+	return engine, nil
+}
+
+// Close releases the temporary engine, if one was created.
+func (s *RequestState) Close() {
+	if closer, ok := s.engine.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,154 @@
+package overleash
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/unleashengine"
+)
+
+func TestParseRequestOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    []RequestOverride
+		wantErr bool
+	}{
+		{
+			name:   "on, off and variant",
+			header: "flagA=on,flagB=off,flagC=variant:blue",
+			want: []RequestOverride{
+				{FeatureFlag: "flagA", Enabled: true},
+				{FeatureFlag: "flagB", Enabled: false},
+				{FeatureFlag: "flagC", Enabled: true, Variant: "blue"},
+			},
+		},
+		{
+			name:   "whitespace and empty entries are ignored",
+			header: " flagA = true , ,flagB=false,",
+			want: []RequestOverride{
+				{FeatureFlag: "flagA", Enabled: true},
+				{FeatureFlag: "flagB", Enabled: false},
+			},
+		},
+		{
+			name:   "empty header",
+			header: "",
+			want:   []RequestOverride{},
+		},
+		{
+			name:    "missing value",
+			header:  "flagA",
+			wantErr: true,
+		},
+		{
+			name:    "unknown value",
+			header:  "flagA=maybe",
+			wantErr: true,
+		},
+		{
+			name:    "missing variant name",
+			header:  "flagA=variant:",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequestOverrides(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRequestOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRequestOverrides() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWithRequestOverrides verifies that request overrides are applied to a
+// temporary state only, and leave the compiled environment untouched.
+func TestWithRequestOverrides(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Token:    "dummy.token",
+		Storage:  "file",
+		Reload:   "0",
+	}
+
+	o := NewOverleash(cfg)
+	o.store = &fakeStore{}
+	o.ActiveFeatureEnvironment().engine = &fakeEngine{}
+	o.ActiveFeatureEnvironment().featureFile = FeatureFile{
+		Version: 1,
+		Features: FeatureFlags{
+			{Name: "flagA", Enabled: false, Strategies: []Strategy{{Name: "original"}}},
+			{Name: "flagB", Enabled: true, Strategies: []Strategy{{Name: "original"}}},
+			{
+				Name:       "flagC",
+				Enabled:    false,
+				Strategies: []Strategy{{Name: "original"}},
+				Variants:   []Variant{{Name: "blue", Payload: Payload{Type: "string", Value: "b"}}},
+			},
+		},
+	}
+	o.compileFeatureFiles()
+
+	engine := &fakeEngine{}
+	newEngine = func() unleashengine.Engine { return engine }
+	defer func() {
+		newEngine = func() unleashengine.Engine { return unleashengine.NewUnleashEngine() }
+	}()
+
+	overrides, err := ParseRequestOverrides("flagA=on,flagB=off,flagC=variant:blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := o.ActiveFeatureEnvironment().WithRequestOverrides(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	flags := state.FeatureFile().Features
+
+	if f, _ := flags.Get("flagA"); !f.Enabled || f.Strategies[0].Name != forceEnable.Name {
+		t.Errorf("Expected flagA to be forced on, got %+v", f)
+	}
+	if f, _ := flags.Get("flagB"); f.Enabled {
+		t.Error("Expected flagB to be off")
+	}
+	f, _ := flags.Get("flagC")
+	if !f.Enabled || len(f.Strategies) != 1 || len(f.Strategies[0].Variants) != 1 {
+		t.Fatalf("Expected flagC to be on with a single variant, got %+v", f)
+	}
+	if v := f.Strategies[0].Variants[0]; v.Name != "blue" || v.Weight != 1000 || v.Payload.Value != "b" {
+		t.Errorf("Unexpected variant for flagC: %+v", v)
+	}
+
+	var decoded FeatureFile
+	if err := json.Unmarshal(state.CachedJson(), &decoded); err != nil {
+		t.Fatalf("CachedJson is not valid JSON: %v", err)
+	}
+	if state.EtagOfCachedJson() == o.ActiveFeatureEnvironment().EtagOfCachedJson() {
+		t.Error("Expected the request state to have its own ETag")
+	}
+
+	if _, err := state.Engine(); err != nil {
+		t.Fatal(err)
+	}
+	if engine.state != string(state.CachedJson()) {
+		t.Error("Expected the temporary engine to be loaded with the request state")
+	}
+
+	// The compiled environment must not have changed.
+	if f, _ := o.ActiveFeatureEnvironment().FeatureFile().Features.Get("flagA"); f.Enabled {
+		t.Error("Request overrides must not leak into the compiled environment")
+	}
+	if len(o.Overrides()) != 0 {
+		t.Error("Request overrides must not be persisted")
+	}
+}
@@ -0,0 +1,155 @@
+package overleash
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/internal/storage"
+)
+
+const testRedisChannel = "overrides-updates"
+
+// newRedisInstance creates an Overleash that shares its overrides through
+// redis, like one replica of a deployment.
+func newRedisInstance(t *testing.T, ctx context.Context, redis *fakeRedis) *OverleashContext {
+	t.Helper()
+
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("a", "b"))
+
+	store := storage.NewRedisStore(storage.RedisConfig{Addr: redis.addr(), Channel: testRedisChannel})
+	o.store = store
+	o.registerEventStore(ctx, store)
+
+	return o
+}
+
+// eventually waits for condition to hold, failing the test after a while.
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// overleashEvents returns the override and paused events subscriber received
+// after its hydration.
+func overleashEvents(subscriber *recordingSubscriber) []Event {
+	subscriber.mutex.Lock()
+	defer subscriber.mutex.Unlock()
+
+	result := make([]Event, 0)
+
+	for _, e := range subscriber.events[1:] {
+		var events Events
+		if err := json.Unmarshal([]byte(e.Data), &events); err != nil {
+			continue
+		}
+
+		for _, event := range events.Events {
+			switch event.(type) {
+			case *OverrideUpdatedEvent, *OverrideRemovedEvent, *PausedChangedEvent:
+				result = append(result, event)
+			}
+		}
+	}
+
+	return result
+}
+
+func TestOverrideChangesPropagateToStreamsOfOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	redis := newFakeRedis(t)
+
+	local := newRedisInstance(t, ctx, redis)
+	remote := newRedisInstance(t, ctx, redis)
+
+	eventually(t, "both instances to subscribe", func() bool {
+		return redis.subscriberCount(testRedisChannel) == 2
+	})
+
+	subscriber := &recordingSubscriber{}
+	remote.ActiveFeatureEnvironment().AddStreamerSubscriber(subscriber, remote, true)
+
+	local.AddOverride("a", true)
+	local.AddOverride("b", false)
+	local.DeleteOverride("a")
+	local.SetPaused(true)
+
+	eventually(t, "the override events on the other instance", func() bool {
+		return len(overleashEvents(subscriber)) >= 4
+	})
+
+	events := overleashEvents(subscriber)
+
+	if e, ok := events[0].(*OverrideUpdatedEvent); !ok || e.Override.FeatureFlag != "a" || !e.Override.Enabled {
+		t.Errorf("Expected a to be enabled first, got %#v", events[0])
+	}
+	if e, ok := events[1].(*OverrideUpdatedEvent); !ok || e.Override.FeatureFlag != "b" || e.Override.Enabled {
+		t.Errorf("Expected b to be disabled second, got %#v", events[1])
+	}
+	if e, ok := events[2].(*OverrideRemovedEvent); !ok || e.FeatureFlag != "a" {
+		t.Errorf("Expected a to be removed third, got %#v", events[2])
+	}
+	if e, ok := events[3].(*PausedChangedEvent); !ok || !e.Paused {
+		t.Errorf("Expected overrides to be paused last, got %#v", events[3])
+	}
+
+	remote.LockMutex.RLock()
+	defer remote.LockMutex.RUnlock()
+
+	if _, ok := remote.overrides["a"]; ok || remote.overrides["b"] == nil || !remote.paused {
+		t.Errorf("Expected the other instance to have the same state, got overrides %v paused %v", remote.overrides, remote.paused)
+	}
+}
+
+func TestRewritingIdenticalOverridesSendsNoEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	redis := newFakeRedis(t)
+
+	local := newRedisInstance(t, ctx, redis)
+	remote := newRedisInstance(t, ctx, redis)
+
+	eventually(t, "both instances to subscribe", func() bool {
+		return redis.subscriberCount(testRedisChannel) == 2
+	})
+
+	subscriber := &recordingSubscriber{}
+	remote.ActiveFeatureEnvironment().AddStreamerSubscriber(subscriber, remote, true)
+
+	value := "42"
+	local.AddOverrideConstraint("a", true, Constraint{ContextName: "userId", Operator: "STR_CONTAINS", Value: &value})
+
+	eventually(t, "the override of a on the other instance", func() bool {
+		return len(overleashEvents(subscriber)) >= 1
+	})
+
+	// Writing b stores a again, unchanged.
+	local.AddOverride("b", true)
+
+	eventually(t, "the override of b on the other instance", func() bool {
+		return len(overleashEvents(subscriber)) >= 2
+	})
+
+	events := overleashEvents(subscriber)
+
+	if len(events) != 2 {
+		t.Fatalf("Expected only the two changed overrides, got %d events", len(events))
+	}
+
+	if e, ok := events[1].(*OverrideUpdatedEvent); !ok || e.Override.FeatureFlag != "b" {
+		t.Errorf("Expected only b to be updated by the second write, got %#v", events[1])
+	}
+}
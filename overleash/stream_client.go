@@ -33,9 +33,31 @@ func (fe *FeatureEnvironment) processSseEvent(event eventsource.Event, o *Overle
 }
 
 func (fe *FeatureEnvironment) processEvents(events Events, o *OverleashContext, main bool) {
+	// The syncs of an upstream Overleash are not syncs of this instance.
+	if events.OnlySynced() {
+		return
+	}
+
 	o.LockMutex.Lock()
 	defer o.LockMutex.Unlock()
 
+	if !fe.applyEvents(events, o, main) {
+		return
+	}
+
+	fe.sync.success(time.Now(), eventsRevision(events))
+	o.lastSync = time.Now()
+	o.processOverleashStreaming(syncedEvents(o.lastSync))
+}
+
+// applyEvents applies delta events to the feature file of the environment and
+// compiles it. The caller holds the lock. It reports false when the events
+// were ignored, e.g. Overleash events for an environment other than the main.
+func (fe *FeatureEnvironment) applyEvents(events Events, o *OverleashContext, main bool) bool {
+	if events.OnlySynced() {
+		return true
+	}
+
 	currentFeatures := make(map[string]Feature)
 
 	for _, f := range fe.featureFile.Features {
@@ -85,14 +107,45 @@ func (fe *FeatureEnvironment) processEvents(events Events, o *OverleashContext,
 
 		case *HydrationOverleashEvent:
 			if !main {
-				return
+				return false
 			}
 
 			o.paused = e.Paused
 			o.overrides = e.Overrides
 
+			// An upstream without overrides may send null.
+			if o.overrides == nil {
+				o.overrides = make(map[string]*Override)
+			}
+
+		case *OverrideUpdatedEvent:
+			if !main {
+				return false
+			}
+
+			if e.Override != nil {
+				o.overrides[e.Override.FeatureFlag] = e.Override
+			}
+
+		case *OverrideRemovedEvent:
+			if !main {
+				return false
+			}
+
+			delete(o.overrides, e.FeatureFlag)
+
+		case *PausedChangedEvent:
+			if !main {
+				return false
+			}
+
+			o.paused = e.Paused
+
+		case *SyncedEvent:
+			// Only informs about the upstream sync, nothing to apply.
+
 		default:
-			return
+			return false
 		}
 	}
 
@@ -112,5 +165,6 @@ func (fe *FeatureEnvironment) processEvents(events Events, o *OverleashContext,
 	fe.featureFile.Segments = segmentSlice
 
 	fe.compile(o)
-	o.lastSync = time.Now()
+
+	return true
 }
@@ -0,0 +1,156 @@
+package overleash
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// streamDelta is one "unleash-updated" message before it is filtered for the
+// project scope of a subscriber.
+type streamDelta struct {
+	id             int
+	events         []Event
+	overleashEvent bool
+}
+
+// sseEvent returns the delta as seen by a subscriber scoped to projects. It
+// returns false when none of the events are visible to the subscriber.
+func (d streamDelta) sseEvent(projects []string) (SseEvent, bool) {
+	events := filterEvents(d.events, projects)
+
+	if len(events) == 0 {
+		return SseEvent{}, false
+	}
+
+	j, _ := json.Marshal(Events{events})
+
+	return SseEvent{
+		Id:             strconv.Itoa(d.id),
+		Event:          "unleash-updated",
+		Data:           string(j),
+		OverleashEvent: d.overleashEvent,
+	}, true
+}
+
+// projectsAllowAll reports whether a project scope is unrestricted.
+func projectsAllowAll(projects []string) bool {
+	return len(projects) == 0 || slices.Contains(projects, "*")
+}
+
+func projectAllowed(projects []string, project string) bool {
+	return projectsAllowAll(projects) || slices.Contains(projects, project)
+}
+
+// filterEvents drops the feature and segment events a subscriber scoped to
+// projects may not see. Segments are not part of a project, so a segment is
+// visible when a feature of one of the projects uses it.
+func filterEvents(events []Event, projects []string) []Event {
+	if projectsAllowAll(projects) {
+		return events
+	}
+
+	filtered := make([]Event, 0, len(events))
+
+	for _, event := range events {
+		switch e := event.(type) {
+		case *FeatureUpdatedEvent:
+			if !projectAllowed(projects, e.Feature.Project) {
+				continue
+			}
+		case *FeatureRemovedEvent:
+			if !projectAllowed(projects, e.Project) {
+				continue
+			}
+		case *SegmentUpdatedEvent:
+			if !slices.ContainsFunc(e.projects, func(project string) bool {
+				return projectAllowed(projects, project)
+			}) {
+				continue
+			}
+		}
+
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
+
+// filterHydration returns the features and segments a subscriber scoped to
+// projects may see.
+func filterHydration(features []Feature, segments []Segment, projects []string) ([]Feature, []Segment) {
+	if projectsAllowAll(projects) {
+		return features, segments
+	}
+
+	filteredFeatures := make([]Feature, 0, len(features))
+	used := make(map[int]bool)
+
+	for _, feature := range features {
+		if !projectAllowed(projects, feature.Project) {
+			continue
+		}
+
+		filteredFeatures = append(filteredFeatures, feature)
+
+		for _, id := range featureSegments(feature) {
+			used[id] = true
+		}
+	}
+
+	filteredSegments := make([]Segment, 0, len(segments))
+
+	for _, segment := range segments {
+		if used[segment.Id] {
+			filteredSegments = append(filteredSegments, segment)
+		}
+	}
+
+	return filteredFeatures, filteredSegments
+}
+
+// featureSegments returns the ids of the segments the strategies of feature
+// use.
+func featureSegments(feature Feature) []int {
+	ids := make([]int, 0)
+
+	for _, strategy := range feature.Strategies {
+		for _, id := range strategy.Segments {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// segmentProjects maps every segment of file to the projects of the features
+// that use it.
+func segmentProjects(file FeatureFile) map[int][]string {
+	m := make(map[int][]string)
+
+	for _, feature := range file.Features {
+		for _, id := range featureSegments(feature) {
+			if !slices.Contains(m[id], feature.Project) {
+				m[id] = append(m[id], feature.Project)
+			}
+		}
+	}
+
+	return m
+}
+
+// scopeKey identifies a project scope, so a delta is only marshalled once per
+// scope.
+func scopeKey(projects []string) string {
+	if projectsAllowAll(projects) {
+		return "*"
+	}
+
+	sorted := slices.Clone(projects)
+	slices.Sort(sorted)
+
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,220 @@
+package overleash
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/launchdarkly/eventsource"
+)
+
+// StreamState is the state of the upstream delta stream of an environment.
+type StreamState string
+
+const (
+	// StreamConnecting means the stream did not connect yet.
+	StreamConnecting StreamState = "connecting"
+	// StreamConnected means the upstream sent unleash-connected and no error
+	// occurred since.
+	StreamConnected StreamState = "connected"
+	// StreamDisconnected means the stream failed or did not connect in time;
+	// the remote is polled until it connects again.
+	StreamDisconnected StreamState = "disconnected"
+)
+
+const (
+	// streamConnectTimeout is how long a stream may take to send
+	// unleash-connected before the remote falls back to polling.
+	streamConnectTimeout = 15 * time.Second
+	// streamFallbackInterval is how often a remote is polled while its
+	// stream is down, unless the reload interval is set.
+	streamFallbackInterval = 30 * time.Second
+)
+
+// StreamStatus is a snapshot of the delta stream of an environment.
+type StreamStatus struct {
+	State StreamState
+	// Since is when the stream entered State.
+	Since     time.Time
+	LastEvent time.Time
+	LastError string
+}
+
+// Polling reports whether the remote is polled because its stream is down.
+func (s StreamStatus) Polling() bool {
+	return s.State == StreamDisconnected
+}
+
+type streamHealth struct {
+	mutex     sync.Mutex
+	state     StreamState
+	since     time.Time
+	lastEvent time.Time
+	lastErr   error
+}
+
+func newStreamHealth(now time.Time) *streamHealth {
+	return &streamHealth{state: StreamConnecting, since: now}
+}
+
+// connected records an unleash-connected event. It reports whether the state
+// changed.
+func (h *streamHealth) connected(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastEvent = now
+	h.lastErr = nil
+
+	return h.setState(StreamConnected, now)
+}
+
+func (h *streamHealth) event(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastEvent = now
+}
+
+// disconnected records a failure of the stream. It reports whether the state
+// changed.
+func (h *streamHealth) disconnected(err error, now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastErr = err
+
+	return h.setState(StreamDisconnected, now)
+}
+
+func (h *streamHealth) setState(state StreamState, now time.Time) bool {
+	if h.state == state {
+		return false
+	}
+
+	h.state = state
+	h.since = now
+
+	return true
+}
+
+func (h *streamHealth) status() StreamStatus {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	status := StreamStatus{
+		State:     h.state,
+		Since:     h.since,
+		LastEvent: h.lastEvent,
+	}
+
+	if h.lastErr != nil {
+		status.LastError = h.lastErr.Error()
+	}
+
+	return status
+}
+
+// StreamStatus returns the state of the upstream delta stream, and false when
+// the remote is not in delta mode.
+func (fe *FeatureEnvironment) StreamStatus() (StreamStatus, bool) {
+	if fe.stream == nil {
+		return StreamStatus{}, false
+	}
+
+	return fe.stream.status(), true
+}
+
+// listenToStream streams the remote at index idx and tracks the health of the
+// stream. While the stream is down the remote is polled instead, until the
+// upstream sends unleash-connected again.
+func (o *OverleashContext) listenToStream(ctx context.Context, idx int, fe *FeatureEnvironment) {
+	channel := make(chan eventsource.Event)
+	errs := make(chan error, 1)
+
+	if err := fe.client.streamFeatures(fe.token, channel, errs); err != nil {
+		reportStreamError(errs, err)
+	}
+
+	interval := o.reload
+
+	if interval <= 0 {
+		interval = streamFallbackInterval
+	}
+
+	connectTimeout := time.NewTimer(streamConnectTimeout)
+	defer connectTimeout.Stop()
+
+	var polling *time.Ticker
+	var poll <-chan time.Time
+
+	startPolling := func(err error) {
+		if !fe.stream.disconnected(err, time.Now()) {
+			return
+		}
+
+		log.Warnf("Stream of %s is down (%v), polling every %s", fe.environment, err, interval)
+
+		polling = time.NewTicker(interval)
+		poll = polling.C
+
+		o.pollStreamingRemote(fe)
+	}
+
+	stopPolling := func() {
+		if polling != nil {
+			polling.Stop()
+			polling, poll = nil, nil
+		}
+	}
+
+	defer stopPolling()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-channel:
+			// An error is reported before the stream reconnects, so one still
+			// pending happened before this event.
+			select {
+			case err := <-errs:
+				startPolling(err)
+			default:
+			}
+
+			if event.Event() == "unleash-connected" {
+				connectTimeout.Stop()
+
+				if fe.stream.connected(time.Now()) {
+					log.Infof("Stream of %s connected", fe.environment)
+					stopPolling()
+				}
+			} else {
+				fe.stream.event(time.Now())
+			}
+
+			fe.processSseEvent(event, o, idx == 0)
+		case err := <-errs:
+			startPolling(err)
+		case <-connectTimeout.C:
+			if status, _ := fe.StreamStatus(); status.State == StreamConnecting {
+				startPolling(fmt.Errorf("no unleash-connected event within %s", streamConnectTimeout))
+			}
+		case <-poll:
+			o.pollStreamingRemote(fe)
+		}
+	}
+}
+
+// pollStreamingRemote fetches all flags of a remote whose stream is down.
+func (o *OverleashContext) pollStreamingRemote(fe *FeatureEnvironment) {
+	o.LockMutex.Lock()
+	defer o.LockMutex.Unlock()
+
+	if err := o.loadRemotes([]*FeatureEnvironment{fe}, false); err != nil {
+		log.Errorf("Unable to poll %s while its stream is down: %v", fe.environment, err)
+	}
+}
@@ -0,0 +1,128 @@
+package overleash
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+)
+
+type testEvent struct {
+	event string
+	data  string
+}
+
+func (e testEvent) Id() string    { return "" }
+func (e testEvent) Event() string { return e.event }
+func (e testEvent) Data() string  { return e.data }
+
+// streamingClient hands the stream channels to the test and counts the
+// fallback fetches.
+type streamingClient struct {
+	fakeClient
+
+	mutex   sync.Mutex
+	channel chan eventsource.Event
+	errs    chan<- error
+	fetches atomic.Int32
+}
+
+func (sc *streamingClient) streamFeatures(token string, channel chan eventsource.Event, errs chan<- error) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	sc.channel = channel
+	sc.errs = errs
+
+	return nil
+}
+
+func (sc *streamingClient) getFeatures(token string, etag string) (*FeatureFile, string, error) {
+	sc.fetches.Add(1)
+
+	return sc.fakeClient.getFeatures(token, etag)
+}
+
+func (sc *streamingClient) stream() (chan eventsource.Event, chan<- error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	return sc.channel, sc.errs
+}
+
+func TestStreamHealthTransitions(t *testing.T) {
+	now := time.Now()
+	h := newStreamHealth(now)
+
+	if status := h.status(); status.State != StreamConnecting || status.Polling() {
+		t.Fatalf("Expected a new stream to be connecting, got %+v", status)
+	}
+
+	if !h.disconnected(errors.New("refused"), now) || h.disconnected(errors.New("refused"), now) {
+		t.Fatal("Expected only the first failure to change the state")
+	}
+
+	if status := h.status(); !status.Polling() || status.LastError != "refused" {
+		t.Fatalf("Expected a disconnected stream to poll, got %+v", status)
+	}
+
+	if !h.connected(now) || h.status().LastError != "" {
+		t.Errorf("Expected connecting to clear the error, got %+v", h.status())
+	}
+}
+
+func TestStreamFallsBackToPollingWhileDown(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	client := &streamingClient{fakeClient: fakeClient{featureFile: featureFileWith("a")}}
+	useClient(o, client)
+
+	env := o.ActiveFeatureEnvironment()
+	env.delta = true
+	env.stream = newStreamHealth(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go o.listenToStream(ctx, 0, env)
+
+	eventually(t, "the stream to be subscribed", func() bool {
+		channel, _ := client.stream()
+		return channel != nil
+	})
+
+	channel, errs := client.stream()
+
+	errs <- errors.New("connection refused")
+
+	eventually(t, "the remote to be polled", func() bool {
+		status, _ := env.StreamStatus()
+		return status.Polling() && client.fetches.Load() == 1
+	})
+
+	o.LockMutex.RLock()
+	features := len(env.featureFile.Features)
+	o.LockMutex.RUnlock()
+
+	if features != 1 {
+		t.Fatalf("Expected the polled flags, got %d", features)
+	}
+
+	channel <- testEvent{event: "unleash-connected", data: `{"events":[{"type":"hydration","eventId":1,"features":[{"name":"a"},{"name":"b"}],"segments":[]}]}`}
+
+	eventually(t, "the stream to be connected", func() bool {
+		status, _ := env.StreamStatus()
+		return status.State == StreamConnected
+	})
+
+	o.LockMutex.RLock()
+	features = len(env.featureFile.Features)
+	o.LockMutex.RUnlock()
+
+	if features != 2 {
+		t.Errorf("Expected the hydration of the stream, got %d flags", features)
+	}
+}
@@ -0,0 +1,84 @@
+package overleash
+
+import (
+	"strconv"
+	"sync"
+)
+
+// StreamHistorySize is the number of emitted events a Streamer keeps to
+// replay to reconnecting subscribers.
+const StreamHistorySize = 64
+
+// streamHistory is a ring buffer of the last emitted deltas. Every delta with
+// an id above floor is in the buffer, so a subscriber that saw floor or later
+// can be brought up to date by replaying the deltas after its last id.
+type streamHistory struct {
+	mutex  sync.Mutex
+	deltas []streamDelta
+	start  int
+	floor  int
+}
+
+func newStreamHistory(floor int) *streamHistory {
+	return &streamHistory{
+		deltas: make([]streamDelta, 0, StreamHistorySize),
+		floor:  floor,
+	}
+}
+
+// add records d. Once full, the oldest delta is evicted and becomes the new
+// floor.
+func (h *streamHistory) add(d streamDelta) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.deltas) < StreamHistorySize {
+		h.deltas = append(h.deltas, d)
+
+		return
+	}
+
+	h.floor = h.deltas[h.start].id
+	h.deltas[h.start] = d
+	h.start = (h.start + 1) % StreamHistorySize
+}
+
+// reset drops all events; subscribers that saw anything before floor have to
+// be hydrated again.
+func (h *streamHistory) reset(floor int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.deltas = h.deltas[:0]
+	h.start = 0
+	h.floor = floor
+}
+
+// since returns the deltas emitted after lastEventId, which must be at most
+// current. It returns false when those deltas are no longer available.
+func (h *streamHistory) since(lastEventId string, current int) ([]streamDelta, bool) {
+	last, err := strconv.Atoi(lastEventId)
+
+	if err != nil {
+		return nil, false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if last < h.floor || last > current {
+		return nil, false
+	}
+
+	missed := make([]streamDelta, 0)
+
+	for i := range h.deltas {
+		d := h.deltas[(h.start+i)%len(h.deltas)]
+
+		if d.id > last {
+			missed = append(missed, d)
+		}
+	}
+
+	return missed, true
+}
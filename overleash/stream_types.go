@@ -1,6 +1,9 @@
 package overleash
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Event interface {
 	GetType() string
@@ -52,6 +55,9 @@ type SegmentUpdatedEvent struct {
 	Type    string  `json:"type"`
 	EventId int     `json:"eventId"`
 	Segment Segment `json:"segment"`
+
+	// projects are the projects of the features using the segment.
+	projects []string
 }
 
 func (e *SegmentUpdatedEvent) GetType() string { return e.Type }
@@ -66,10 +72,62 @@ type SegmentRemovedEvent struct {
 func (e *SegmentRemovedEvent) GetType() string { return e.Type }
 func (e *SegmentRemovedEvent) GetEventId() int { return e.EventId }
 
+type OverrideUpdatedEvent struct {
+	Type     string    `json:"type"`
+	EventId  int       `json:"eventId"`
+	Override *Override `json:"override"`
+}
+
+func (e *OverrideUpdatedEvent) GetType() string { return e.Type }
+func (e *OverrideUpdatedEvent) GetEventId() int { return e.EventId }
+
+type OverrideRemovedEvent struct {
+	Type        string `json:"type"`
+	EventId     int    `json:"eventId"`
+	FeatureFlag string `json:"featureFlag"`
+}
+
+func (e *OverrideRemovedEvent) GetType() string { return e.Type }
+func (e *OverrideRemovedEvent) GetEventId() int { return e.EventId }
+
+type PausedChangedEvent struct {
+	Type    string `json:"type"`
+	EventId int    `json:"eventId"`
+	Paused  bool   `json:"paused"`
+}
+
+func (e *PausedChangedEvent) GetType() string { return e.Type }
+func (e *PausedChangedEvent) GetEventId() int { return e.EventId }
+
+type SyncedEvent struct {
+	Type     string    `json:"type"`
+	EventId  int       `json:"eventId"`
+	LastSync time.Time `json:"lastSync"`
+}
+
+func (e *SyncedEvent) GetType() string { return e.Type }
+func (e *SyncedEvent) GetEventId() int { return e.EventId }
+
 type Events struct {
 	Events []Event `json:"events"`
 }
 
+// OnlySynced reports whether the batch only tells about a sync with the
+// upstream, which leaves the flags and overrides as they are.
+func (e Events) OnlySynced() bool {
+	if len(e.Events) == 0 {
+		return false
+	}
+
+	for _, event := range e.Events {
+		if _, ok := event.(*SyncedEvent); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 // UnmarshalJSON implements custom unmarshaling for ClientFeaturesDelta
 func (ev *Events) UnmarshalJSON(data []byte) error {
 	// First unmarshal to get the raw events
@@ -132,6 +190,30 @@ func (ev *Events) UnmarshalJSON(data []byte) error {
 				event = &e
 			}
 
+		case "override-updated":
+			var e OverrideUpdatedEvent
+			if err := json.Unmarshal(rawEvent, &e); err == nil {
+				event = &e
+			}
+
+		case "override-removed":
+			var e OverrideRemovedEvent
+			if err := json.Unmarshal(rawEvent, &e); err == nil {
+				event = &e
+			}
+
+		case "paused-changed":
+			var e PausedChangedEvent
+			if err := json.Unmarshal(rawEvent, &e); err == nil {
+				event = &e
+			}
+
+		case "synced":
+			var e SyncedEvent
+			if err := json.Unmarshal(rawEvent, &e); err == nil {
+				event = &e
+			}
+
 		default:
 			// Unknown event type - skip
 			continue
@@ -2,6 +2,8 @@ package overleash
 
 import (
 	"encoding/json"
+	"maps"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -15,6 +17,9 @@ type StreamSubscriber interface {
 	Notify(e SseEvent)
 	UseActiveEnvironment() bool
 	IsOverleashClient() bool
+	// Projects is the project scope of the subscriber's token; empty or "*"
+	// means all projects.
+	Projects() []string
 }
 
 type SseEvent struct {
@@ -24,25 +29,103 @@ type SseEvent struct {
 	OverleashEvent bool
 }
 
+// Streamer delivers the delta events of one environment to its subscribers.
+//
+// All events are produced by jobs that run one at a time, in the order they
+// were enqueued, so subscribers see changes in the order they were made and
+// event ids only ever increase.
 type Streamer struct {
 	subscribers []StreamSubscriber
 	mutex       sync.RWMutex
 	i           atomic.Int64
+	history     *streamHistory
+
+	queueMutex sync.Mutex
+	queue      []func()
+	draining   bool
+}
+
+// Subscribers returns the number of clients subscribed to the environment.
+func (s *Streamer) Subscribers() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.subscribers)
+}
+
+// enqueue runs job after every job enqueued before it. Jobs run on a separate
+// goroutine, so enqueue never blocks the caller.
+func (s *Streamer) enqueue(job func()) {
+	s.queueMutex.Lock()
+	defer s.queueMutex.Unlock()
+
+	s.queue = append(s.queue, job)
+
+	if s.draining {
+		return
+	}
+
+	s.draining = true
+	go s.drain()
+}
+
+func (s *Streamer) drain() {
+	for {
+		s.queueMutex.Lock()
+
+		if len(s.queue) == 0 {
+			s.draining = false
+			s.queueMutex.Unlock()
+
+			return
+		}
+
+		job := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.queueMutex.Unlock()
+
+		job()
+	}
 }
 
 func (s *Streamer) NotifyWithNewUpdateDelta(id int, events []Event, overleashEvent bool) {
-	j, _ := json.Marshal(Events{events})
+	d := streamDelta{
+		id:             id,
+		events:         events,
+		overleashEvent: overleashEvent,
+	}
+
+	s.history.add(d)
+
+	type scoped struct {
+		e       SseEvent
+		visible bool
+	}
+
+	byScope := make(map[string]scoped)
 
 	for _, subscriber := range s.subscribers {
-		subscriber.Notify(SseEvent{
-			Id:             strconv.Itoa(id),
-			Event:          "unleash-updated",
-			Data:           string(j),
-			OverleashEvent: overleashEvent,
-		})
+		key := scopeKey(subscriber.Projects())
+		sc, ok := byScope[key]
+
+		if !ok {
+			sc.e, sc.visible = d.sseEvent(subscriber.Projects())
+			byScope[key] = sc
+		}
+
+		if sc.visible {
+			subscriber.Notify(sc.e)
+		}
 	}
 }
 
+// skip is called instead of emitting events when nobody is listening. The
+// history would have a gap, so it is dropped.
+func (s *Streamer) skip() {
+	s.history.reset(int(s.i.Add(1)))
+}
+
 func (s *Streamer) createNewConnectDelta(id int, events []Event) SseEvent {
 	j, _ := json.Marshal(Events{events})
 
@@ -55,11 +138,18 @@ func (s *Streamer) createNewConnectDelta(id int, events []Event) SseEvent {
 }
 
 func NewStreamer() *Streamer {
-	return &Streamer{
+	s := &Streamer{
 		subscribers: make([]StreamSubscriber, 0),
 		mutex:       sync.RWMutex{},
 		i:           atomic.Int64{},
 	}
+
+	// Ids start at the current time so that ids of a previous run are lower
+	// and a client reconnecting after a restart is hydrated, not resumed.
+	s.i.Store(time.Now().UnixMilli())
+	s.history = newStreamHistory(int(s.i.Load()))
+
+	return s
 }
 
 func (fe *FeatureEnvironment) AddStreamerSubscriber(client StreamSubscriber, o *OverleashContext, withLock bool) {
@@ -69,14 +159,26 @@ func (fe *FeatureEnvironment) AddStreamerSubscriber(client StreamSubscriber, o *
 	}
 
 	fe.Streamer.subscribers = append(fe.Streamer.subscribers, client)
+	fe.hydrate(client, o)
+}
+
+// hydrate sends client the current state. It may be newer than deltas that
+// are still queued; those are sent afterwards and only repeat changes that
+// are already part of the hydration.
+func (fe *FeatureEnvironment) hydrate(client StreamSubscriber, o *OverleashContext) {
+	o.LockMutex.RLock()
+	defer o.LockMutex.RUnlock()
+
+	features, segments := filterHydration(fe.cachedFeatureFile.Features, fe.cachedFeatureFile.Segments, client.Projects())
+	originalFeatures, _ := filterHydration(fe.featureFile.Features, nil, client.Projects())
 
 	events := []Event{
 		&HydrationEvent{
 			Type:             "hydration",
 			EventId:          1,
-			Features:         fe.cachedFeatureFile.Features,
-			Segments:         fe.cachedFeatureFile.Segments,
-			OriginalFeatures: fe.featureFile.Features,
+			Features:         features,
+			Segments:         segments,
+			OriginalFeatures: originalFeatures,
 		},
 	}
 
@@ -91,7 +193,64 @@ func (fe *FeatureEnvironment) AddStreamerSubscriber(client StreamSubscriber, o *
 		)
 	}
 
-	client.Notify(fe.Streamer.createNewConnectDelta(1, events))
+	client.Notify(fe.Streamer.createNewConnectDelta(int(fe.Streamer.i.Load()), events))
+}
+
+// ResyncStreamerSubscriber sends client a fresh hydration from the environment
+// it is subscribed to, for a client that missed events.
+func (o *OverleashContext) ResyncStreamerSubscriber(client StreamSubscriber) {
+	for _, fe := range o.featureEnvironments {
+		if fe.Streamer == nil {
+			continue
+		}
+
+		fe.Streamer.mutex.Lock()
+		subscribed := slices.Contains(fe.Streamer.subscribers, client)
+
+		if subscribed {
+			fe.hydrate(client, o)
+		}
+		fe.Streamer.mutex.Unlock()
+
+		if subscribed {
+			return
+		}
+	}
+}
+
+// ResumeStreamerSubscriber adds client and replays the events it missed after
+// lastEventId. When those are no longer in the history the client is hydrated
+// as if it connected for the first time.
+func (fe *FeatureEnvironment) ResumeStreamerSubscriber(client StreamSubscriber, o *OverleashContext, lastEventId string) {
+	fe.Streamer.mutex.Lock()
+	defer fe.Streamer.mutex.Unlock()
+
+	missed, ok := fe.Streamer.history.since(lastEventId, int(fe.Streamer.i.Load()))
+
+	if !ok {
+		fe.AddStreamerSubscriber(client, o, false)
+
+		return
+	}
+
+	fe.Streamer.subscribers = append(fe.Streamer.subscribers, client)
+
+	for _, d := range missed {
+		if e, ok := d.sseEvent(client.Projects()); ok {
+			client.Notify(e)
+		}
+	}
+}
+
+// RemoveStreamerSubscriber removes client from whichever environment it is
+// subscribed to; subscribers that use the active environment move along when
+// the active environment changes.
+func (o *OverleashContext) RemoveStreamerSubscriber(client StreamSubscriber) {
+	for _, fe := range o.featureEnvironments {
+		if fe.Streamer != nil {
+			fe.RemoveStreamerSubscriber(client, true)
+		}
+	}
 }
 
 func (fe *FeatureEnvironment) RemoveStreamerSubscriber(client StreamSubscriber, withLock bool) {
@@ -109,28 +268,125 @@ func (fe *FeatureEnvironment) RemoveStreamerSubscriber(client StreamSubscriber,
 	fe.Streamer.subscribers = newSubs
 }
 
-func (o *OverleashContext) processOverleashStreaming() {
+// processOverleashStreaming sends the events created by build to the
+// subscribers of every environment. build is called once per environment, as
+// event ids are per Streamer.
+func (o *OverleashContext) processOverleashStreaming(build func(nextId func() int) []Event) {
 	for _, e := range o.featureEnvironments {
 		if e.Streamer != nil {
-			if len(e.Streamer.subscribers) == 0 {
-				continue
-			}
+			s := e.Streamer
+			s.enqueue(func() {
+				s.processOverleash(build)
+			})
+		}
+	}
+}
 
-			id := int(e.Streamer.i.Add(1))
-			events := []Event{
-				&HydrationOverleashEvent{
-					Type:      "hydration-overleash",
-					EventId:   int(e.Streamer.i.Add(1)),
-					Overrides: o.overrides,
-					Paused:    o.paused,
-				},
-			}
+func (s *Streamer) processOverleash(build func(nextId func() int) []Event) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-			if len(events) == 0 {
-				return
-			}
+	if len(s.subscribers) == 0 {
+		s.skip()
+		return
+	}
+
+	id := int(s.i.Add(1))
+	events := build(func() int {
+		return int(s.i.Add(1))
+	})
+
+	if len(events) == 0 {
+		return
+	}
 
-			e.Streamer.NotifyWithNewUpdateDelta(id, events, true)
+	s.NotifyWithNewUpdateDelta(id, events, true)
+}
+
+func overrideUpdatedEvents(override Override) func(nextId func() int) []Event {
+	return func(nextId func() int) []Event {
+		return []Event{
+			&OverrideUpdatedEvent{
+				Type:     "override-updated",
+				EventId:  nextId(),
+				Override: &override,
+			},
+		}
+	}
+}
+
+func overrideRemovedEvents(featureFlags ...string) func(nextId func() int) []Event {
+	return func(nextId func() int) []Event {
+		events := make([]Event, 0, len(featureFlags))
+
+		for _, featureFlag := range featureFlags {
+			events = append(events, &OverrideRemovedEvent{
+				Type:        "override-removed",
+				EventId:     nextId(),
+				FeatureFlag: featureFlag,
+			})
+		}
+
+		return events
+	}
+}
+
+// overridesChangedEvents describes the difference between two sets of
+// overrides, e.g. when another instance changed them.
+func overridesChangedEvents(previous, current map[string]*Override) func(nextId func() int) []Event {
+	updated := make([]Override, 0)
+
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		if old, ok := previous[name]; !ok || !cmp.Equal(old, current[name]) {
+			updated = append(updated, *current[name])
+		}
+	}
+
+	removed := make([]string, 0)
+
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	return func(nextId func() int) []Event {
+		events := make([]Event, 0, len(updated)+len(removed))
+
+		for _, override := range updated {
+			events = append(events, &OverrideUpdatedEvent{
+				Type:     "override-updated",
+				EventId:  nextId(),
+				Override: &override,
+			})
+		}
+
+		events = append(events, overrideRemovedEvents(removed...)(nextId)...)
+
+		return events
+	}
+}
+
+func syncedEvents(lastSync time.Time) func(nextId func() int) []Event {
+	return func(nextId func() int) []Event {
+		return []Event{
+			&SyncedEvent{
+				Type:     "synced",
+				EventId:  nextId(),
+				LastSync: lastSync,
+			},
+		}
+	}
+}
+
+func pausedChangedEvents(paused bool) func(nextId func() int) []Event {
+	return func(nextId func() int) []Event {
+		return []Event{
+			&PausedChangedEvent{
+				Type:    "paused-changed",
+				EventId: nextId(),
+				Paused:  paused,
+			},
 		}
 	}
 }
@@ -176,6 +432,7 @@ func (s *Streamer) processFeature(old, new, remote FeatureFile) {
 
 	if len(s.subscribers) == 0 {
 		log.Debug("No subscribers, skipping processing")
+		s.skip()
 		return
 	}
 
@@ -186,6 +443,10 @@ func (s *Streamer) processFeature(old, new, remote FeatureFile) {
 	originalFlag := keyFeatureFlags(remote)
 
 	events := make([]Event, 0)
+	// newlyUsed maps the segments that changed features started to use to
+	// the projects of those features. A subscriber scoped to such a project
+	// may not have the segment yet, even when it did not change.
+	newlyUsed := make(map[int][]string)
 
 	id := int(s.i.Add(1))
 	for flagName, feature := range newFlagsMap {
@@ -194,6 +455,16 @@ func (s *Streamer) processFeature(old, new, remote FeatureFile) {
 		if !ok || !cmp.Equal(oldFeature, feature) {
 			originalFeature, _ := originalFlag[flagName]
 
+			for _, segmentId := range featureSegments(feature) {
+				if ok && oldFeature.Project == feature.Project && slices.Contains(featureSegments(oldFeature), segmentId) {
+					continue
+				}
+
+				if !slices.Contains(newlyUsed[segmentId], feature.Project) {
+					newlyUsed[segmentId] = append(newlyUsed[segmentId], feature.Project)
+				}
+			}
+
 			events = append(events, &FeatureUpdatedEvent{
 				Type:            "feature-updated",
 				EventId:         int(s.i.Add(1)),
@@ -216,19 +487,30 @@ func (s *Streamer) processFeature(old, new, remote FeatureFile) {
 
 	oldSegments := keySegments(old)
 	newSegments := keySegments(new)
+	projectsOfSegment := segmentProjects(new)
 
 	for id, segment := range newSegments {
 		oldSegment, ok := oldSegments[id]
 
 		if !ok || !cmp.Equal(oldSegment, segment) {
 			events = append(events, &SegmentUpdatedEvent{
-				Type:    "segment-updated",
-				EventId: int(s.i.Add(1)),
-				Segment: segment,
+				Type:     "segment-updated",
+				EventId:  int(s.i.Add(1)),
+				Segment:  segment,
+				projects: projectsOfSegment[id],
 			})
 
 			continue
 		}
+
+		if projects, used := newlyUsed[id]; used {
+			events = append(events, &SegmentUpdatedEvent{
+				Type:     "segment-updated",
+				EventId:  int(s.i.Add(1)),
+				Segment:  segment,
+				projects: projects,
+			})
+		}
 	}
 
 	for _, m := range missingSegments(oldSegments, newSegments) {
@@ -369,7 +651,7 @@ func (s Segment) Equal(other Segment) bool {
 func (c Constraint) Equal(other Constraint) bool {
 	if c.ContextName != other.ContextName ||
 		c.Operator != other.Operator ||
-		c.Value != other.Value ||
+		!equalValue(c.Value, other.Value) ||
 		c.CaseInsensitive != other.CaseInsensitive ||
 		c.Inverted != other.Inverted {
 		return false
@@ -387,3 +669,13 @@ func (c Constraint) Equal(other Constraint) bool {
 
 	return true
 }
+
+// equalValue compares single constraint values by content, as unmarshalling
+// the same overrides twice gives different pointers.
+func equalValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,522 @@
+package overleash
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// recordingSubscriber keeps every event it is notified of.
+type recordingSubscriber struct {
+	mutex    sync.Mutex
+	events   []SseEvent
+	projects []string
+}
+
+func (r *recordingSubscriber) Notify(e SseEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, e)
+}
+
+func (r *recordingSubscriber) UseActiveEnvironment() bool { return false }
+func (r *recordingSubscriber) IsOverleashClient() bool    { return true }
+func (r *recordingSubscriber) Projects() []string         { return r.projects }
+
+// flushStreamer waits until every job enqueued so far has run.
+func flushStreamer(s *Streamer) {
+	done := make(chan struct{})
+	s.enqueue(func() { close(done) })
+	<-done
+}
+
+func featureFileWith(names ...string) FeatureFile {
+	file := FeatureFile{}
+
+	for _, name := range names {
+		file.Features = append(file.Features, Feature{Name: name, Enabled: true})
+	}
+
+	return file
+}
+
+func newStreamingEnvironment() (*FeatureEnvironment, *OverleashContext) {
+	return &FeatureEnvironment{Streamer: NewStreamer()}, &OverleashContext{overrides: map[string]*Override{}}
+}
+
+func TestResumeStreamerSubscriberReplaysMissedEvents(t *testing.T) {
+	fe, o := newStreamingEnvironment()
+
+	first := &recordingSubscriber{}
+	fe.AddStreamerSubscriber(first, o, true)
+	connectedId := first.events[0].Id
+
+	fe.Streamer.processFeature(FeatureFile{}, featureFileWith("a"), FeatureFile{})
+	fe.Streamer.processFeature(featureFileWith("a"), featureFileWith("a", "b"), FeatureFile{})
+
+	if len(first.events) != 3 {
+		t.Fatalf("Expected a connect and two updates, got %d events", len(first.events))
+	}
+
+	resumed := &recordingSubscriber{}
+	fe.ResumeStreamerSubscriber(resumed, o, connectedId)
+
+	if len(resumed.events) != 2 || resumed.events[0].Event != "unleash-updated" {
+		t.Fatalf("Expected the two missed updates to be replayed, got %v", resumed.events)
+	}
+
+	upToDate := &recordingSubscriber{}
+	fe.ResumeStreamerSubscriber(upToDate, o, first.events[2].Id)
+
+	if len(upToDate.events) != 0 {
+		t.Errorf("Expected nothing to be replayed to an up to date client, got %v", upToDate.events)
+	}
+}
+
+func TestResumeStreamerSubscriberFallsBackToHydration(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventId func(fe *FeatureEnvironment, connectedId string) string
+	}{
+		{
+			name:        "not a number",
+			lastEventId: func(*FeatureEnvironment, string) string { return "abc" },
+		},
+		{
+			name: "from the future",
+			lastEventId: func(fe *FeatureEnvironment, _ string) string {
+				return strconv.FormatInt(fe.Streamer.i.Load()+1, 10)
+			},
+		},
+		{
+			name: "evicted",
+			lastEventId: func(fe *FeatureEnvironment, connectedId string) string {
+				names := []string{}
+				for i := 0; i <= StreamHistorySize; i++ {
+					old := featureFileWith(names...)
+					names = append(names, strconv.Itoa(i))
+					fe.Streamer.processFeature(old, featureFileWith(names...), FeatureFile{})
+				}
+
+				return connectedId
+			},
+		},
+		{
+			name: "skipped while nobody was listening",
+			lastEventId: func(fe *FeatureEnvironment, connectedId string) string {
+				fe.Streamer.subscribers = nil
+				fe.Streamer.processFeature(FeatureFile{}, featureFileWith("a"), FeatureFile{})
+
+				return connectedId
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, o := newStreamingEnvironment()
+
+			first := &recordingSubscriber{}
+			fe.AddStreamerSubscriber(first, o, true)
+			lastEventId := tt.lastEventId(fe, first.events[0].Id)
+
+			resumed := &recordingSubscriber{}
+			fe.ResumeStreamerSubscriber(resumed, o, lastEventId)
+
+			if len(resumed.events) != 1 || resumed.events[0].Event != "unleash-connected" {
+				t.Errorf("Expected a hydration, got %v", resumed.events)
+			}
+		})
+	}
+}
+
+func TestOverrideEventsRoundTrip(t *testing.T) {
+	fe, o := newStreamingEnvironment()
+	o.featureEnvironments = []*FeatureEnvironment{fe}
+
+	subscriber := &recordingSubscriber{}
+	fe.AddStreamerSubscriber(subscriber, o, true)
+
+	o.processOverleashStreaming(overrideUpdatedEvents(Override{FeatureFlag: "a", Enabled: true, IsGlobal: true}))
+	o.processOverleashStreaming(overrideRemovedEvents("b", "c"))
+	o.processOverleashStreaming(pausedChangedEvents(true))
+	flushStreamer(fe.Streamer)
+
+	wantTypes := [][]string{
+		{"override-updated"},
+		{"override-removed", "override-removed"},
+		{"paused-changed"},
+	}
+
+	if len(subscriber.events) != len(wantTypes)+1 {
+		t.Fatalf("Expected a connect and %d updates, got %d events", len(wantTypes), len(subscriber.events))
+	}
+
+	for i, want := range wantTypes {
+		e := subscriber.events[i+1]
+
+		if !e.OverleashEvent {
+			t.Errorf("Expected %v to only be sent to Overleash clients", want)
+		}
+
+		var events Events
+		if err := json.Unmarshal([]byte(e.Data), &events); err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]string, 0, len(events.Events))
+		for _, event := range events.Events {
+			got = append(got, event.GetType())
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected event types %v, got %v", want, got)
+		}
+	}
+}
+
+func TestProcessEventsAppliesOverrideEvents(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("a", "b"))
+	o.overrides["b"] = &Override{FeatureFlag: "b", Enabled: true, IsGlobal: true}
+
+	o.ActiveFeatureEnvironment().processEvents(Events{Events: []Event{
+		&OverrideUpdatedEvent{Type: "override-updated", Override: &Override{FeatureFlag: "a", Enabled: false, IsGlobal: true}},
+		&OverrideRemovedEvent{Type: "override-removed", FeatureFlag: "b"},
+		&PausedChangedEvent{Type: "paused-changed", Paused: true},
+	}}, o, true)
+
+	if o.overrides["a"] == nil || o.overrides["a"].Enabled {
+		t.Errorf("Expected override for a to be applied, got %v", o.overrides["a"])
+	}
+	if _, ok := o.overrides["b"]; ok {
+		t.Error("Expected override for b to be removed")
+	}
+	if !o.paused {
+		t.Error("Expected paused to be applied")
+	}
+}
+
+// streamState is the state a subscriber builds up from the events it receives.
+type streamState struct {
+	features  map[string]bool
+	overrides map[string]bool
+	paused    bool
+}
+
+// replay applies the events of subscriber in order and fails when their ids
+// do not strictly increase.
+func replay(t *testing.T, subscriber *recordingSubscriber) streamState {
+	t.Helper()
+
+	subscriber.mutex.Lock()
+	defer subscriber.mutex.Unlock()
+
+	state := streamState{features: map[string]bool{}, overrides: map[string]bool{}}
+	lastId := int64(0)
+
+	for _, e := range subscriber.events {
+		id, err := strconv.ParseInt(e.Id, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Event == "unleash-updated" && id <= lastId {
+			t.Fatalf("Expected increasing ids, got %d after %d", id, lastId)
+		}
+		lastId = id
+
+		var events Events
+		if err := json.Unmarshal([]byte(e.Data), &events); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, event := range events.Events {
+			switch ev := event.(type) {
+			case *HydrationEvent:
+				state.features = map[string]bool{}
+				for _, f := range ev.Features {
+					state.features[f.Name] = f.Enabled
+				}
+			case *HydrationOverleashEvent:
+				state.overrides = map[string]bool{}
+				for name, override := range ev.Overrides {
+					state.overrides[name] = override.Enabled
+				}
+				state.paused = ev.Paused
+			case *FeatureUpdatedEvent:
+				state.features[ev.Feature.Name] = ev.Feature.Enabled
+			case *FeatureRemovedEvent:
+				delete(state.features, ev.FeatureName)
+			case *OverrideUpdatedEvent:
+				state.overrides[ev.Override.FeatureFlag] = ev.Override.Enabled
+			case *OverrideRemovedEvent:
+				delete(state.overrides, ev.FeatureFlag)
+			case *PausedChangedEvent:
+				state.paused = ev.Paused
+			}
+		}
+	}
+
+	return state
+}
+
+func TestStreamerDeliversConcurrentChangesInOrder(t *testing.T) {
+	flags := []string{"a", "b", "c", "d"}
+
+	o, _ := newEvaluationTestOverleash(t, featureFileWith(flags...))
+	fe := o.ActiveFeatureEnvironment()
+
+	early := &recordingSubscriber{}
+	fe.AddStreamerSubscriber(early, o, true)
+
+	late := &recordingSubscriber{}
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				flag := flags[(worker+i)%len(flags)]
+
+				switch i % 5 {
+				case 0, 1:
+					o.AddOverride(flag, (worker+i)%2 == 0)
+				case 2:
+					o.AddOverrideConstraint(flag, true, Constraint{ContextName: "userId", Operator: OperatorIn, Values: []string{"1"}})
+				case 3:
+					o.DeleteOverride(flag)
+				case 4:
+					o.SetPaused(i%2 == 0)
+				}
+
+				if worker == 0 && i == 25 {
+					fe.AddStreamerSubscriber(late, o, true)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	flushStreamer(fe.Streamer)
+
+	o.LockMutex.RLock()
+	want := streamState{features: map[string]bool{}, overrides: map[string]bool{}, paused: o.paused}
+	for _, f := range fe.cachedFeatureFile.Features {
+		want.features[f.Name] = f.Enabled
+	}
+	for name, override := range o.overrides {
+		want.overrides[name] = override.Enabled
+	}
+	o.LockMutex.RUnlock()
+
+	for name, subscriber := range map[string]*recordingSubscriber{"early": early, "late": late} {
+		t.Run(name, func(t *testing.T) {
+			if got := replay(t, subscriber); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected final state %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func featureInProject(name, project string, segments ...int) Feature {
+	return Feature{Name: name, Project: project, Enabled: true, Strategies: []Strategy{{Name: "default", Segments: segments}}}
+}
+
+func TestStreamerFiltersByProject(t *testing.T) {
+	initial := FeatureFile{
+		Features: []Feature{featureInProject("a", "alpha", 1), featureInProject("b", "beta", 2)},
+		Segments: []Segment{{Id: 1, Name: "one"}, {Id: 2, Name: "two"}},
+	}
+	changed := FeatureFile{
+		Features: []Feature{featureInProject("b", "beta", 2)},
+		Segments: []Segment{{Id: 1, Name: "one"}, {Id: 2, Name: "two, renamed"}},
+	}
+
+	fe, o := newStreamingEnvironment()
+	fe.cachedFeatureFile = initial
+	fe.featureFile = initial
+
+	tests := []struct {
+		name          string
+		projects      []string
+		wantFeatures  []string
+		wantSegments  []int
+		wantDeltaType []string
+	}{
+		{
+			name:          "all projects",
+			wantFeatures:  []string{"a", "b"},
+			wantSegments:  []int{1, 2},
+			wantDeltaType: []string{"feature-removed", "segment-updated"},
+		},
+		{
+			name:          "alpha",
+			projects:      []string{"alpha"},
+			wantFeatures:  []string{"a"},
+			wantSegments:  []int{1},
+			wantDeltaType: []string{"feature-removed"},
+		},
+		{
+			name:          "beta",
+			projects:      []string{"beta"},
+			wantFeatures:  []string{"b"},
+			wantSegments:  []int{2},
+			wantDeltaType: []string{"segment-updated"},
+		},
+	}
+
+	subscribers := make([]*recordingSubscriber, len(tests))
+	for i, tt := range tests {
+		subscribers[i] = &recordingSubscriber{projects: tt.projects}
+		fe.AddStreamerSubscriber(subscribers[i], o, true)
+	}
+
+	fe.Streamer.processFeature(initial, changed, changed)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := subscribers[i].events
+			if len(events) != 2 {
+				t.Fatalf("Expected a connect and an update, got %d events", len(events))
+			}
+
+			var hydration Events
+			if err := json.Unmarshal([]byte(events[0].Data), &hydration); err != nil {
+				t.Fatal(err)
+			}
+
+			h := hydration.Events[0].(*HydrationEvent)
+			gotFeatures := []string{}
+			for _, f := range h.Features {
+				gotFeatures = append(gotFeatures, f.Name)
+			}
+			gotSegments := []int{}
+			for _, s := range h.Segments {
+				gotSegments = append(gotSegments, s.Id)
+			}
+
+			if !reflect.DeepEqual(gotFeatures, tt.wantFeatures) || !reflect.DeepEqual(gotSegments, tt.wantSegments) {
+				t.Errorf("Expected hydration with %v and segments %v, got %v and %v", tt.wantFeatures, tt.wantSegments, gotFeatures, gotSegments)
+			}
+
+			var delta Events
+			if err := json.Unmarshal([]byte(events[1].Data), &delta); err != nil {
+				t.Fatal(err)
+			}
+
+			gotTypes := []string{}
+			for _, e := range delta.Events {
+				gotTypes = append(gotTypes, e.GetType())
+			}
+
+			if !reflect.DeepEqual(gotTypes, tt.wantDeltaType) {
+				t.Errorf("Expected delta %v, got %v", tt.wantDeltaType, gotTypes)
+			}
+		})
+	}
+
+	// A subscriber that sees none of the changes gets nothing, also on resume.
+	gamma := &recordingSubscriber{projects: []string{"gamma"}}
+	fe.ResumeStreamerSubscriber(gamma, o, subscribers[0].events[0].Id)
+
+	if len(gamma.events) != 0 {
+		t.Errorf("Expected no events for an unrelated project, got %v", gamma.events)
+	}
+}
+
+func TestStreamerSendsSegmentsNewlyUsedInAProject(t *testing.T) {
+	initial := FeatureFile{
+		Features: []Feature{featureInProject("a", "alpha"), featureInProject("b", "beta", 2)},
+		Segments: []Segment{{Id: 2, Name: "two"}},
+	}
+	changed := FeatureFile{
+		Features: []Feature{featureInProject("a", "alpha", 2), featureInProject("b", "beta", 2)},
+		Segments: []Segment{{Id: 2, Name: "two"}},
+	}
+
+	fe, o := newStreamingEnvironment()
+	fe.cachedFeatureFile = initial
+	fe.featureFile = initial
+
+	alpha := &recordingSubscriber{projects: []string{"alpha"}}
+	fe.AddStreamerSubscriber(alpha, o, true)
+	connectedId := alpha.events[0].Id
+
+	fe.Streamer.processFeature(initial, changed, changed)
+
+	resumed := &recordingSubscriber{projects: []string{"alpha"}}
+	fe.ResumeStreamerSubscriber(resumed, o, connectedId)
+
+	for name, subscriber := range map[string]*recordingSubscriber{"live": alpha, "resumed": resumed} {
+		events := subscriber.events
+		if len(events) == 0 {
+			t.Fatalf("Expected the %s subscriber to get the update", name)
+		}
+
+		var delta Events
+		if err := json.Unmarshal([]byte(events[len(events)-1].Data), &delta); err != nil {
+			t.Fatal(err)
+		}
+
+		gotTypes := []string{}
+		for _, e := range delta.Events {
+			gotTypes = append(gotTypes, e.GetType())
+		}
+
+		if !reflect.DeepEqual(gotTypes, []string{"feature-updated", "segment-updated"}) {
+			t.Errorf("Expected the %s subscriber to get the feature and its segment, got %v", name, gotTypes)
+		}
+	}
+
+	beta := &recordingSubscriber{projects: []string{"beta"}}
+	fe.ResumeStreamerSubscriber(beta, o, connectedId)
+
+	if len(beta.events) != 0 {
+		t.Errorf("Expected no events for a project that already used the segment, got %v", beta.events)
+	}
+}
+
+func TestMultiProjectTokenSeesOnlyItsProjects(t *testing.T) {
+	initial := FeatureFile{
+		Features: []Feature{featureInProject("a", "alpha", 1), featureInProject("b", "beta", 2)},
+	}
+	changed := FeatureFile{
+		Features: []Feature{featureInProject("a", "alpha", 1)},
+	}
+
+	fe, o := newStreamingEnvironment()
+	fe.client = &validatingClient{projects: []string{"alpha", "gamma"}}
+	fe.cachedFeatureFile = initial
+	fe.featureFile = initial
+
+	projects, err := fe.TokenProjects("[]:development.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriber := &recordingSubscriber{projects: projects}
+	fe.AddStreamerSubscriber(subscriber, o, true)
+
+	// Only a feature of another project is removed.
+	fe.Streamer.processFeature(initial, changed, changed)
+
+	if len(subscriber.events) != 1 {
+		t.Fatalf("Expected only the hydration, got %v", subscriber.events)
+	}
+
+	var hydration Events
+	if err := json.Unmarshal([]byte(subscriber.events[0].Data), &hydration); err != nil {
+		t.Fatal(err)
+	}
+
+	features := hydration.Events[0].(*HydrationEvent).Features
+	if len(features) != 1 || features[0].Name != "a" {
+		t.Errorf("Expected only the feature of alpha, got %v", features)
+	}
+}
@@ -0,0 +1,136 @@
+package overleash
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyncStatus is a snapshot of how an environment syncs with its upstream.
+type SyncStatus struct {
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastErrorAt time.Time
+	LastError   string
+	// Revision is the ETag of the feature file, or the revision of the last
+	// applied delta.
+	Revision string
+	// Stale is set when the environment did not sync within the staleness
+	// threshold, counting from the start when it never synced.
+	Stale bool
+	// Restored is set when the flags were restored from a backup at startup.
+	Restored bool
+}
+
+// HasData reports whether the environment has flags to serve, either synced
+// or restored from a backup.
+func (s SyncStatus) HasData() bool {
+	return !s.LastSuccess.IsZero() || s.Restored
+}
+
+// Failing reports whether the last attempt to sync failed.
+func (s SyncStatus) Failing() bool {
+	return s.LastError != "" && !s.LastErrorAt.Before(s.LastSuccess)
+}
+
+// syncTracker records the syncs of an environment with its upstream.
+type syncTracker struct {
+	mutex       sync.Mutex
+	started     time.Time
+	lastAttempt time.Time
+	lastSuccess time.Time
+	lastErrorAt time.Time
+	lastErr     error
+	revision    string
+	restored    bool
+}
+
+func (s *syncTracker) attempt(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastAttempt = now
+}
+
+// success records a sync at now. An empty revision keeps the previous one.
+func (s *syncTracker) success(now time.Time, revision string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastAttempt = now
+	s.lastSuccess = now
+
+	if revision != "" {
+		s.revision = revision
+	}
+}
+
+// restore records that the flags were restored from a backup.
+func (s *syncTracker) restore() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.restored = true
+}
+
+func (s *syncTracker) failure(err error, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastErrorAt = now
+	s.lastErr = err
+}
+
+// status returns the sync status at now. It is stale when staleAfter is set
+// and passed since the last success.
+func (s *syncTracker) status(now time.Time, staleAfter time.Duration) SyncStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := SyncStatus{
+		LastAttempt: s.lastAttempt,
+		LastSuccess: s.lastSuccess,
+		LastErrorAt: s.lastErrorAt,
+		Revision:    s.revision,
+		Restored:    s.restored,
+	}
+
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+
+	if staleAfter > 0 {
+		last := s.lastSuccess
+
+		if last.IsZero() {
+			last = s.started
+		}
+
+		status.Stale = now.Sub(last) > staleAfter
+	}
+
+	return status
+}
+
+// SyncStatus returns how the environment syncs with its upstream. A connected
+// delta stream is never stale, as it only sends events when flags change, and
+// neither are offline fixtures.
+func (fe *FeatureEnvironment) SyncStatus() SyncStatus {
+	staleAfter := fe.staleAfter
+
+	if stream, ok := fe.StreamStatus(); ok && stream.State == StreamConnected || fe.fixture != "" {
+		staleAfter = 0
+	}
+
+	return fe.sync.status(time.Now(), staleAfter)
+}
+
+// eventsRevision returns the revision of delta events: the quoted id of the
+// last event, or "" when there are none.
+func eventsRevision(events Events) string {
+	if len(events.Events) == 0 {
+		return ""
+	}
+
+	return strconv.Quote(strconv.Itoa(events.Events[len(events.Events)-1].GetEventId()))
+}
@@ -0,0 +1,137 @@
+package overleash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSyncTrackerStaleness(t *testing.T) {
+	start := time.Now()
+	s := syncTracker{started: start}
+
+	if s.status(start.Add(time.Hour), 0).Stale {
+		t.Error("Expected no staleness without a threshold")
+	}
+
+	if !s.status(start.Add(2*time.Minute), time.Minute).Stale {
+		t.Error("Expected an environment that never synced to be stale after the threshold")
+	}
+
+	s.success(start.Add(90*time.Second), `"abc"`)
+
+	if s.status(start.Add(2*time.Minute), time.Minute).Stale {
+		t.Error("Expected a recent sync not to be stale")
+	}
+
+	s.success(start.Add(100*time.Second), "")
+
+	if status := s.status(start.Add(2*time.Minute), time.Minute); status.Revision != `"abc"` {
+		t.Errorf("Expected an empty revision to keep the previous one, got %q", status.Revision)
+	}
+}
+
+func TestLoadRemotesTracksSyncStatus(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	client := &fakeClient{err: errors.New("connection refused")}
+	useClient(o, client)
+
+	env := o.ActiveFeatureEnvironment()
+	env.staleAfter = time.Minute
+
+	if err := o.loadRemotes(o.featureEnvironments, false); err == nil {
+		t.Fatal("Expected the error of the upstream")
+	}
+
+	status := env.SyncStatus()
+
+	if !status.Failing() || status.LastError != "connection refused" || status.LastAttempt.IsZero() || !status.LastSuccess.IsZero() {
+		t.Fatalf("Expected a failed attempt, got %+v", status)
+	}
+
+	if status.Stale {
+		t.Error("Expected an environment to not be stale right after the start")
+	}
+
+	client.err = nil
+	client.featureFile = featureFileWith("a")
+	env.breaker.success()
+
+	if err := o.loadRemotes(o.featureEnvironments, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	status = env.SyncStatus()
+
+	if status.Failing() || status.LastSuccess.IsZero() || status.LastError != "connection refused" {
+		t.Errorf("Expected a success that keeps the last error, got %+v", status)
+	}
+}
+
+func TestStreamedEventsTrackRevision(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	env := o.ActiveFeatureEnvironment()
+
+	env.processSseEvent(testEvent{event: "unleash-updated", data: `{"events":[{"type":"feature-removed","eventId":7,"featureName":"a","project":"default"}]}`}, o, false)
+
+	if status := env.SyncStatus(); status.Revision != `"7"` || status.LastSuccess.IsZero() {
+		t.Errorf("Expected the revision of the last event, got %+v", status)
+	}
+}
+
+func TestStreamedSyncedEventsAreNotApplied(t *testing.T) {
+	o, engine := newEvaluationTestOverleash(t, featureFileWith("a"))
+	env := o.ActiveFeatureEnvironment()
+
+	if _, _, err := env.ResolveAll(userContext("1"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	env.processSseEvent(testEvent{event: "unleash-updated", data: `{"events":[{"type":"synced","eventId":7,"lastSync":"2026-01-01T00:00:00Z"}]}`}, o, true)
+
+	if status := env.SyncStatus(); !status.LastSuccess.IsZero() || status.Revision != "" {
+		t.Errorf("Expected the sync of the upstream not to count as one, got %+v", status)
+	}
+
+	if _, hit, _ := env.ResolveAll(userContext("1"), false); !hit || engine.calls != 1 {
+		t.Errorf("Expected the environment not to be recompiled, engine called %d times", engine.calls)
+	}
+}
+
+func TestStreamedOverridesAfterNullHydration(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("a"))
+	env := o.ActiveFeatureEnvironment()
+
+	env.processSseEvent(testEvent{event: "unleash-connected", data: `{"events":[{"type":"hydration-overleash","eventId":1,"overrides":null,"paused":false}]}`}, o, true)
+	env.processSseEvent(testEvent{event: "unleash-updated", data: `{"events":[{"type":"override-updated","eventId":2,"override":{"featureFlag":"a","enabled":true}}]}`}, o, true)
+
+	if override, ok := o.overrides["a"]; !ok || !override.Enabled {
+		t.Errorf("Expected the streamed override to be stored, got %v", o.overrides)
+	}
+}
+
+func TestStartRestoringBackupHasData(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	o.Config.Backup = true
+	useClient(o, &fakeClient{err: errors.New("connection refused")})
+
+	env := o.ActiveFeatureEnvironment()
+
+	if env.SyncStatus().HasData() {
+		t.Fatal("Expected no data before the start")
+	}
+
+	data, _ := json.Marshal(featureFileWith("a"))
+	o.store.Write(env.Name()+"-backup.json", data)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	o.Start(ctx)
+
+	if status := env.SyncStatus(); !status.HasData() || !status.Restored || !status.LastSuccess.IsZero() {
+		t.Errorf("Expected the backup to count as data, got %+v", status)
+	}
+}
@@ -1,7 +1,9 @@
 package overleash
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -65,3 +67,46 @@ func ExtractEnvironment(token string) (string, error) {
 
 	return subParts[0], nil
 }
+
+// TokenProjects returns the project scope of an Unleash token, nil meaning all
+// projects. The projects of a multi-project "[]" token are only known
+// upstream, so they are validated there once and remembered. It returns an
+// error when the scope cannot be determined, including for tokens it cannot
+// parse.
+func (fe *FeatureEnvironment) TokenProjects(token string) ([]string, error) {
+	edgeToken, ok := fromString(token)
+
+	if !ok {
+		return nil, errors.New("invalid token format")
+	}
+
+	if slices.Contains(edgeToken.Projects, "*") {
+		return nil, nil
+	}
+
+	if !slices.Contains(edgeToken.Projects, "[]") {
+		return edgeToken.Projects, nil
+	}
+
+	if projects, ok := fe.tokenProjects.Load(token); ok {
+		return projects.([]string), nil
+	}
+
+	if fe.client == nil {
+		return nil, errors.New("unable to validate the projects of the token")
+	}
+
+	validated, err := fe.client.validateToken(token)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate the projects of the token: %w", err)
+	}
+
+	if validated == nil || len(validated.Projects) == 0 || slices.Contains(validated.Projects, "[]") {
+		return nil, errors.New("the upstream returned no projects for the token")
+	}
+
+	fe.tokenProjects.Store(token, validated.Projects)
+
+	return validated.Projects, nil
+}
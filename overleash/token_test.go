@@ -1,6 +1,8 @@
 package overleash
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -94,3 +96,70 @@ func TestExtractEnvironment(t *testing.T) {
 		})
 	}
 }
+
+// validatingClient validates tokens as having projects, counting the
+// validations.
+type validatingClient struct {
+	fakeClient
+	projects    []string
+	validations int
+}
+
+func (vc *validatingClient) validateToken(token string) (*EdgeToken, error) {
+	vc.validations++
+
+	if vc.err != nil {
+		return nil, vc.err
+	}
+
+	return &EdgeToken{Token: token, Projects: vc.projects}, nil
+}
+
+func TestTokenProjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		client  client
+		want    []string
+		wantErr bool
+	}{
+		{name: "single project", token: "default:development.key", want: []string{"default"}},
+		{name: "all projects", token: "*:development.key", want: nil},
+		{name: "multiple projects", token: "[]:development.key", client: &validatingClient{projects: []string{"alpha", "beta"}}, want: []string{"alpha", "beta"}},
+		{name: "multiple projects failing validation", token: "[]:development.key", client: &validatingClient{fakeClient: fakeClient{err: errors.New("unauthorized")}}, wantErr: true},
+		{name: "multiple projects without projects", token: "[]:development.key", client: &validatingClient{}, wantErr: true},
+		{name: "multiple projects without a client", token: "[]:development.key", wantErr: true},
+		{name: "not an unleash token", token: "secret", wantErr: true},
+		{name: "no token", token: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe := &FeatureEnvironment{client: tt.client}
+			got, err := fe.TokenProjects(tt.token)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TokenProjects() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TokenProjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenProjectsRemembersValidatedTokens(t *testing.T) {
+	client := &validatingClient{projects: []string{"alpha"}}
+	fe := &FeatureEnvironment{client: client}
+
+	for range 2 {
+		if _, err := fe.TokenProjects("[]:development.key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if client.validations != 1 {
+		t.Errorf("Expected the token to be validated once, got %d", client.validations)
+	}
+}
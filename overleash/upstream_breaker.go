@@ -0,0 +1,193 @@
+package overleash
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker that guards the upstream of
+// an environment.
+type CircuitState string
+
+const (
+	// CircuitClosed means the upstream is fetched as usual.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen means the upstream failed too often; it is left alone until
+	// the backoff expires and the last good state is served meanwhile.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen means the next fetch probes whether the upstream is back.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+const (
+	// circuitFailureThreshold is the number of consecutive failures after
+	// which the circuit opens.
+	circuitFailureThreshold = 5
+	// maxUpstreamBackoff caps the delay between two fetches of a failing
+	// upstream, unless the upstream asks for more with Retry-After.
+	maxUpstreamBackoff = 5 * time.Minute
+)
+
+// UpstreamStatus is a snapshot of the circuit breaker of an environment.
+type UpstreamStatus struct {
+	State     CircuitState
+	Failures  int
+	RetryAt   time.Time
+	LastError string
+}
+
+// upstreamBreaker backs off exponentially from a failing upstream and opens the
+// circuit after consecutive failures.
+type upstreamBreaker struct {
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+// allow reports whether the upstream may be fetched at now. An open circuit
+// becomes half-open once its backoff expired.
+func (b *upstreamBreaker) allow(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if now.Before(b.retryAt) {
+		return false
+	}
+
+	if b.state == CircuitOpen {
+		b.state = CircuitHalfOpen
+	}
+
+	return true
+}
+
+// probe lets an explicit fetch through regardless of the backoff. An open
+// circuit becomes half-open, so the fetch decides whether it closes.
+func (b *upstreamBreaker) probe() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitOpen {
+		b.state = CircuitHalfOpen
+	}
+}
+
+// success closes the circuit. It reports whether the state changed.
+func (b *upstreamBreaker) success() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	changed := b.failures > 0
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.retryAt = time.Time{}
+	b.lastErr = nil
+
+	return changed
+}
+
+// failure records a failed fetch at now and schedules the next one, base being
+// the regular reload interval. It reports whether the state changed.
+func (b *upstreamBreaker) failure(err error, now time.Time, base time.Duration) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	previous := b.currentState()
+
+	b.failures++
+	b.lastErr = err
+
+	delay := backoff(base, b.failures)
+
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > delay {
+		delay = upstreamErr.RetryAfter
+	}
+
+	b.retryAt = now.Add(delay)
+
+	if b.state == CircuitHalfOpen || b.failures >= circuitFailureThreshold {
+		b.state = CircuitOpen
+	}
+
+	return b.currentState() != previous || b.failures == 1
+}
+
+func (b *upstreamBreaker) currentState() CircuitState {
+	if b.state == "" {
+		return CircuitClosed
+	}
+
+	return b.state
+}
+
+func (b *upstreamBreaker) status() UpstreamStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := UpstreamStatus{
+		State:    b.currentState(),
+		Failures: b.failures,
+		RetryAt:  b.retryAt,
+	}
+
+	if b.lastErr != nil {
+		status.LastError = b.lastErr.Error()
+	}
+
+	return status
+}
+
+// backoff returns the delay before the next fetch after failures consecutive
+// failures: base doubled for every failure after the first, capped at
+// maxUpstreamBackoff, with jitter so replicas do not retry in lockstep.
+func backoff(base time.Duration, failures int) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+
+	limit := max(base, maxUpstreamBackoff)
+	delay := base
+
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, limit)
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+
+	return 0
+}
+
+// UpstreamStatus returns the state of the circuit breaker guarding the
+// upstream of the environment.
+func (fe *FeatureEnvironment) UpstreamStatus() UpstreamStatus {
+	return fe.breaker.status()
+}
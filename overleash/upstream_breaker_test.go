@@ -0,0 +1,177 @@
+package overleash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/internal/httpclient"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		failures int
+		min      time.Duration
+		max      time.Duration
+	}{
+		{name: "first failure waits for the next reload", base: 10 * time.Second, failures: 1, min: 5 * time.Second, max: 10 * time.Second},
+		{name: "doubles per failure", base: 10 * time.Second, failures: 3, min: 20 * time.Second, max: 40 * time.Second},
+		{name: "capped", base: 10 * time.Second, failures: 20, min: maxUpstreamBackoff / 2, max: maxUpstreamBackoff},
+		{name: "never below the reload interval", base: 10 * time.Minute, failures: 5, min: 5 * time.Minute, max: 10 * time.Minute},
+		{name: "without reload interval", base: 0, failures: 1, min: 500 * time.Millisecond, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				if got := backoff(tt.base, tt.failures); got < tt.min || got > tt.max {
+					t.Fatalf("Expected a backoff between %s and %s, got %s", tt.min, tt.max, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "missing", value: "", want: 0},
+		{name: "seconds", value: "120", want: 2 * time.Minute},
+		{name: "date", value: "Wed, 01 Jan 2025 12:01:00 GMT", want: time.Minute},
+		{name: "date in the past", value: "Wed, 01 Jan 2025 11:00:00 GMT", want: 0},
+		{name: "invalid", value: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUpstreamBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	var b upstreamBreaker
+	now := time.Now()
+	err := errors.New("connection refused")
+
+	for i := 1; i < circuitFailureThreshold; i++ {
+		b.failure(err, now, time.Second)
+
+		if state := b.status().State; state != CircuitClosed {
+			t.Fatalf("Expected the circuit to stay closed after %d failures, got %s", i, state)
+		}
+	}
+
+	b.failure(err, now, time.Second)
+
+	status := b.status()
+	if status.State != CircuitOpen || status.LastError != err.Error() {
+		t.Fatalf("Expected the circuit to open, got %+v", status)
+	}
+	if b.allow(now) {
+		t.Fatal("Expected no fetch while the backoff runs")
+	}
+	if !b.allow(status.RetryAt) || b.status().State != CircuitHalfOpen {
+		t.Fatalf("Expected a probe once the backoff expired, got %s", b.status().State)
+	}
+
+	b.failure(err, status.RetryAt, time.Second)
+
+	if state := b.status().State; state != CircuitOpen {
+		t.Fatalf("Expected a failed probe to open the circuit again, got %s", state)
+	}
+
+	if !b.success() || b.status() != (UpstreamStatus{State: CircuitClosed}) {
+		t.Errorf("Expected a success to close the circuit, got %+v", b.status())
+	}
+}
+
+func TestUpstreamBreakerHonoursRetryAfter(t *testing.T) {
+	var b upstreamBreaker
+	now := time.Now()
+
+	b.failure(&UpstreamError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}, now, time.Second)
+
+	if retryAt := b.status().RetryAt; !retryAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected to retry after an hour, got %s", retryAt.Sub(now))
+	}
+}
+
+func TestLoadRemotesBacksOffFromFailingUpstream(t *testing.T) {
+	var requests atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	o, _ := newEvaluationTestOverleash(t, featureFileWith("a"))
+	useClient(o, newClient(newUpstreamPool(ts.URL), 1, httpclient.New(nil, 0), context.Background()))
+	env := o.ActiveFeatureEnvironment()
+
+	if err := o.loadPollingRemotesWithLock(); err == nil {
+		t.Fatal("Expected the rate limited fetch to fail")
+	}
+
+	if err := o.loadPollingRemotesWithLock(); err != nil {
+		t.Fatalf("Expected the upstream to be skipped, got %v", err)
+	}
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single request while backing off, got %d", n)
+	}
+
+	if status := env.UpstreamStatus(); status.Failures != 1 || status.RetryAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expected one failure and a retry in an hour, got %+v", status)
+	}
+
+	if len(env.featureFile.Features) != 1 {
+		t.Errorf("Expected the last good state to be kept, got %v", env.featureFile.Features)
+	}
+}
+
+func TestRefreshProbesBackingOffUpstream(t *testing.T) {
+	o, _ := newEvaluationTestOverleash(t, FeatureFile{})
+	client := &fakeClient{err: errors.New("connection refused")}
+	useClient(o, client)
+	env := o.ActiveFeatureEnvironment()
+
+	for range circuitFailureThreshold {
+		env.breaker.failure(client.err, time.Now(), time.Hour)
+	}
+
+	if err := o.RefreshFeatureFiles(); err == nil {
+		t.Fatal("Expected the refresh to report the failing upstream")
+	}
+
+	if status := env.UpstreamStatus(); status.State != CircuitOpen || status.Failures != circuitFailureThreshold+1 {
+		t.Fatalf("Expected the failed probe to keep the circuit open, got %+v", status)
+	}
+
+	client.err = nil
+	client.featureFile = featureFileWith("a")
+
+	if err := o.RefreshFeatureFiles(); err != nil {
+		t.Fatalf("Expected the refresh to bypass the backoff, got %v", err)
+	}
+
+	if status := env.UpstreamStatus(); status.State != CircuitClosed || len(env.featureFile.Features) != 1 {
+		t.Errorf("Expected the refresh to close the circuit, got %+v", status)
+	}
+}
@@ -0,0 +1,124 @@
+package overleash
+
+import (
+	"sync"
+	"time"
+)
+
+// upstreamFailoverBackoff is the base delay before a failed upstream of a
+// remote with several upstreams is tried again.
+const upstreamFailoverBackoff = 10 * time.Second
+
+// upstreamPool is the ordered list of upstreams of a remote. Requests go to the
+// first healthy upstream; a failed upstream is skipped until its backoff
+// expired, after which it is preferred again over the ones after it.
+type upstreamPool struct {
+	mutex    sync.Mutex
+	urls     []string
+	failures []int
+	retryAt  []time.Time
+	current  int
+}
+
+func newUpstreamPool(urls ...string) *upstreamPool {
+	if len(urls) == 0 {
+		urls = []string{""}
+	}
+
+	return &upstreamPool{
+		urls:     urls,
+		failures: make([]int, len(urls)),
+		retryAt:  make([]time.Time, len(urls)),
+	}
+}
+
+// candidates returns the indexes of the upstreams to try at now, in order of
+// preference. Upstreams that are backing off are left out, unless all of them
+// are.
+func (p *upstreamPool) candidates(now time.Time) []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	healthy := make([]int, 0, len(p.urls))
+	all := make([]int, len(p.urls))
+
+	for i := range p.urls {
+		all[i] = i
+
+		if !now.Before(p.retryAt[i]) {
+			healthy = append(healthy, i)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return all
+	}
+
+	return healthy
+}
+
+// succeeded marks upstream i healthy and serving. It reports whether another
+// upstream was serving before.
+func (p *upstreamPool) succeeded(i int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.failures[i] = 0
+	p.retryAt[i] = time.Time{}
+
+	switched := p.current != i
+	p.current = i
+
+	return switched
+}
+
+// failed records a failure of upstream i at now.
+func (p *upstreamPool) failed(i int, now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.failures[i]++
+	p.retryAt[i] = now.Add(backoff(upstreamFailoverBackoff, p.failures[i]))
+}
+
+// failbackIn returns how long until an upstream preferred over the serving one
+// may be tried again, and false when the first upstream is serving.
+func (p *upstreamPool) failbackIn(now time.Time) (time.Duration, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.current == 0 {
+		return 0, false
+	}
+
+	next := p.retryAt[0]
+
+	for i := 1; i < p.current; i++ {
+		if p.retryAt[i].Before(next) {
+			next = p.retryAt[i]
+		}
+	}
+
+	return max(next.Sub(now), time.Second), true
+}
+
+func (p *upstreamPool) url(i int) string {
+	return p.urls[i]
+}
+
+func (p *upstreamPool) len() int {
+	return len(p.urls)
+}
+
+// serving returns the upstream that served the last successful request, or the
+// first one before any request succeeded.
+func (p *upstreamPool) serving() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.urls[p.current]
+}
+
+func (p *upstreamPool) all() []string {
+	return append([]string(nil), p.urls...)
+}
@@ -0,0 +1,153 @@
+package overleash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/internal/httpclient"
+	"github.com/launchdarkly/eventsource"
+)
+
+func TestUpstreamPoolFailsOverAndBack(t *testing.T) {
+	p := newUpstreamPool("http://primary", "http://secondary", "http://tertiary")
+	now := time.Now()
+
+	if got := p.candidates(now); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Fatalf("Expected all upstreams in order, got %v", got)
+	}
+
+	p.failed(0, now)
+
+	if got := p.candidates(now); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("Expected the failed upstream to be skipped, got %v", got)
+	}
+
+	if !p.succeeded(1) || p.serving() != "http://secondary" {
+		t.Fatalf("Expected the secondary to serve, got %s", p.serving())
+	}
+
+	delay, ok := p.failbackIn(now)
+	if !ok || delay > upstreamFailoverBackoff {
+		t.Fatalf("Expected a fail-back within %s, got %s", upstreamFailoverBackoff, delay)
+	}
+
+	if got := p.candidates(now.Add(upstreamFailoverBackoff)); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Fatalf("Expected the primary to be preferred again after its backoff, got %v", got)
+	}
+
+	if !p.succeeded(0) || p.serving() != "http://primary" {
+		t.Fatalf("Expected the primary to serve, got %s", p.serving())
+	}
+
+	if _, ok := p.failbackIn(now); ok {
+		t.Error("Expected no fail-back while the primary serves")
+	}
+}
+
+func TestUpstreamPoolTriesAllWhenAllFailed(t *testing.T) {
+	p := newUpstreamPool("http://primary", "http://secondary")
+	now := time.Now()
+
+	p.failed(0, now)
+	p.failed(1, now)
+
+	if got := p.candidates(now); !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("Expected all upstreams when all are failing, got %v", got)
+	}
+}
+
+func TestGetFeaturesFailsOverToNextUpstream(t *testing.T) {
+	var primaryRequests atomic.Int32
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":1,"features":[{"name":"a","enabled":true}]}`))
+	}))
+	defer secondary.Close()
+
+	p := newUpstreamPool(primary.URL, secondary.URL)
+	c := newClient(p, 1, httpclient.New(nil, 0), context.Background())
+
+	features, _, err := c.getFeatures("token", "")
+	if err != nil {
+		t.Fatalf("Expected the secondary to serve, got %v", err)
+	}
+
+	if len(features.Features) != 1 || p.serving() != secondary.URL {
+		t.Fatalf("Expected the features of the secondary, got %v from %s", features.Features, p.serving())
+	}
+
+	if _, _, err := c.getFeatures("token", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := primaryRequests.Load(); n != 1 {
+		t.Errorf("Expected the primary to be skipped while backing off, got %d requests", n)
+	}
+}
+
+func TestGetFeaturesReportsAllUpstreamErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	c := newClient(newUpstreamPool(ts.URL, ts.URL+"/other"), 1, httpclient.New(nil, 0), context.Background())
+
+	_, _, err := c.getFeatures("token", "")
+
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected an upstream error, got %v", err)
+	}
+}
+
+func TestStreamFeaturesFailsOverToNextUpstream(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: unleash-connected\ndata: {}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer secondary.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := newUpstreamPool(primary.URL, secondary.URL)
+	c := newClient(p, 1, httpclient.New(nil, 0), ctx)
+	channel := make(chan eventsource.Event)
+
+	if err := c.streamFeatures("token", channel, make(chan error, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-channel:
+		if event.Event() != "unleash-connected" {
+			t.Errorf("Expected the connected event, got %s", event.Event())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event from the secondary")
+	}
+
+	if p.serving() != secondary.URL {
+		t.Errorf("Expected the secondary to serve the stream, got %s", p.serving())
+	}
+}
@@ -1,38 +1,21 @@
 package proxy
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
-	"time"
-)
-
-var (
-	httpClient *http.Client
 )
 
 type Proxy struct {
-	upstream string
-}
-
-func init() {
-	httpClient = &http.Client{
-		// Do not auto-follow redirects
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{}, // Use system's default trusted CA pool
-		},
-		Timeout: 10 * time.Second,
-	}
+	upstream   string
+	httpClient *http.Client
 }
 
-func New(upstream string) *Proxy {
-	return &Proxy{upstream: upstream}
+// New returns a proxy to upstream that sends its requests through httpClient.
+func New(upstream string, httpClient *http.Client) *Proxy {
+	return &Proxy{upstream: upstream, httpClient: httpClient}
 }
 
 func (p *Proxy) ProxyRequest(w http.ResponseWriter, req *http.Request) error {
@@ -56,7 +39,7 @@ func (p *Proxy) ProxyRequest(w http.ResponseWriter, req *http.Request) error {
 	req.URL = newUrl
 	req.Host = newUrl.Host
 
-	proxiedResponse, err := httpClient.Do(req)
+	proxiedResponse, err := p.httpClient.Do(req)
 
 	if err != nil {
 		return err
@@ -6,6 +6,9 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/internal/httpclient"
 )
 
 // TestProxyRequest sets up an upstream server and verifies that
@@ -20,7 +23,7 @@ func TestProxyRequest(t *testing.T) {
 	defer upstreamServer.Close()
 
 	// Create a Proxy instance using the upstream server URL.
-	proxyInstance := New(upstreamServer.URL)
+	proxyInstance := New(upstreamServer.URL, httpclient.New(nil, 10*time.Second))
 
 	// Build a request that will be proxied.
 	// For example, let’s use a nonempty path and a query parameter.
@@ -71,7 +74,7 @@ func TestProxyRequest_PathConcatenation(t *testing.T) {
 
 	// Append a base path to the upstream URL.
 	baseURL := upstreamServer.URL + "/base"
-	proxyInstance := New(baseURL)
+	proxyInstance := New(baseURL, httpclient.New(nil, 10*time.Second))
 
 	// Create a request with a path to be concatenated.
 	req := httptest.NewRequest("GET", "/subpath", nil)
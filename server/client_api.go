@@ -4,37 +4,85 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/Iandenh/overleash/overleash"
 )
 
 func (c *Server) registerClientApi(s *http.ServeMux) {
 	s.Handle("GET /api/client/features", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Overleash.LockMutex.RLock()
+		defer c.Overleash.LockMutex.RUnlock()
+
 		env := c.featureEnvironmentFromRequest(r)
 
-		ifNoneMatch := strings.Trim(strings.TrimPrefix(r.Header.Get("If-None-Match"), "W/"), "\"")
+		state, err := c.requestState(r, env)
 
-		if ifNoneMatch != "" && ifNoneMatch == env.EtagOfCachedJson() {
-			w.WriteHeader(http.StatusNotModified)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
+		etag, body := env.EtagOfCachedJson(), env.CachedJson()
+
+		if state != nil {
+			defer state.Close()
+
+			etag, body = state.EtagOfCachedJson(), state.CachedJson()
+		}
+
 		h := w.Header()
-		h.Set("ETag", fmt.Sprintf("W/\"%s\"", env.EtagOfCachedJson()))
+		h.Add("Vary", "Accept-Encoding")
+
+		if matchesEtag(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		// This route bypasses the compression middleware: the feature file is
+		// compressed once per compile instead of on every poll. Request
+		// overrides are rare enough to be served uncompressed.
+		if state == nil {
+			if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+				if compressed, ok := env.CompressedJson(encoding); ok {
+					h.Set("Content-Encoding", encoding)
+					body = compressed
+				}
+			}
+		}
+
+		h.Set("ETag", fmt.Sprintf("W/\"%s\"", etag))
 		h.Add("Content-Type", "application/json")
+		h.Set("Content-Length", strconv.Itoa(len(body)))
 		w.WriteHeader(http.StatusOK)
 
-		w.Write(env.CachedJson())
+		w.Write(body)
 	}))
 
 	s.Handle("GET /api/client/features/{key}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := r.PathValue("key")
 
+		env := c.featureEnvironmentFromRequest(r)
+
+		state, err := c.requestState(r, env)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		featureFile := env.FeatureFile()
+
+		if state != nil {
+			defer state.Close()
+
+			featureFile = state.FeatureFile()
+		}
+
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
-		flag, _ := c.featureEnvironmentFromRequest(r).FeatureFile().Features.Get(key)
+		flag, _ := featureFile.Features.Get(key)
 
 		writer := json.NewEncoder(w)
 
@@ -154,6 +154,10 @@ func (c *Server) registerDashboardApi(s *http.ServeMux) {
 	})
 
 	s.HandleFunc("GET /dashboard/lastSync", func(w http.ResponseWriter, request *http.Request) {
-		templ.Handler(lastSync(c.Overleash.LastSync())).ServeHTTP(w, request)
+		templ.Handler(lastSync(c.Overleash)).ServeHTTP(w, request)
+	})
+
+	s.HandleFunc("GET /dashboard/environmentSync", func(w http.ResponseWriter, request *http.Request) {
+		templ.Handler(environmentSync(c.Overleash.ActiveFeatureEnvironment().SyncStatus())).ServeHTTP(w, request)
 	})
 }
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Iandenh/overleash/overleash"
+	"github.com/charmbracelet/log"
+)
+
+// Events sent to the dashboard. The dashboard script reacts to each of them by
+// fetching the affected fragment through htmx.
+const (
+	// dashboardFlagEvent carries the name of a flag whose card is outdated.
+	dashboardFlagEvent = "flag"
+	// dashboardListEvent means flags were added or removed.
+	dashboardListEvent = "list"
+	// dashboardRefreshEvent means the whole dashboard is outdated, e.g. after
+	// pausing or switching remotes.
+	dashboardRefreshEvent = "refresh"
+	// dashboardSyncEvent means the last sync time changed.
+	dashboardSyncEvent = "sync"
+)
+
+// dashboardEvents translates streamer events into dashboard events. The first
+// hydration is the state the dashboard was rendered with; any later one means
+// the remote changed or the dashboard missed events.
+func dashboardEvents() func(e overleash.SseEvent) []overleash.SseEvent {
+	hydrated := false
+
+	return func(e overleash.SseEvent) []overleash.SseEvent {
+		if e.Event == "unleash-connected" {
+			if !hydrated {
+				hydrated = true
+				return nil
+			}
+
+			return []overleash.SseEvent{{Event: dashboardRefreshEvent}}
+		}
+
+		var events overleash.Events
+
+		if err := json.Unmarshal([]byte(e.Data), &events); err != nil {
+			log.Errorf("Unable to unmarshal event data for the dashboard: %v", err)
+			return []overleash.SseEvent{{Event: dashboardRefreshEvent}}
+		}
+
+		flags := make([]string, 0, len(events.Events))
+		seen := make(map[string]bool)
+		result := make([]overleash.SseEvent, 0)
+
+		flag := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				flags = append(flags, name)
+			}
+		}
+
+		for _, event := range events.Events {
+			switch ev := event.(type) {
+			case *overleash.FeatureUpdatedEvent:
+				flag(ev.Feature.Name)
+			case *overleash.FeatureRemovedEvent:
+				result = append(result, overleash.SseEvent{Event: dashboardListEvent})
+			case *overleash.OverrideUpdatedEvent:
+				flag(ev.Override.FeatureFlag)
+			case *overleash.OverrideRemovedEvent:
+				flag(ev.FeatureFlag)
+			case *overleash.PausedChangedEvent:
+				return []overleash.SseEvent{{Event: dashboardRefreshEvent}}
+			case *overleash.SyncedEvent:
+				result = append(result, overleash.SseEvent{Event: dashboardSyncEvent})
+			}
+		}
+
+		for _, name := range flags {
+			result = append(result, overleash.SseEvent{Event: dashboardFlagEvent, Data: name})
+		}
+
+		return result
+	}
+}
+
+func (c *Server) registerDashboardEvents(s *http.ServeMux) {
+	s.HandleFunc("GET /dashboard/events", func(w http.ResponseWriter, r *http.Request) {
+		env := c.Overleash.ActiveFeatureEnvironment()
+
+		if env.Streamer == nil {
+			http.Error(w, "Streaming unsupported", http.StatusNotFound)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		rc := http.NewResponseController(w)
+
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("failed to set write deadline: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		subscriber := &httpSubscriber{
+			flusher:              flusher,
+			writer:               w,
+			isOverleashClient:    true,
+			useActiveEnvironment: true,
+			send:                 make(chan overleash.SseEvent, overleash.StreamHistorySize+32),
+			policy:               c.Overleash.Config.StreamSlowSubscriber,
+			resync:               make(chan struct{}, 1),
+			cancel:               cancel,
+			translate:            dashboardEvents(),
+		}
+
+		env.AddStreamerSubscriber(subscriber, c.Overleash, true)
+		defer c.Overleash.RemoveStreamerSubscriber(subscriber)
+
+		go subscriber.run(ctx, c.Overleash)
+
+		<-ctx.Done()
+	})
+}
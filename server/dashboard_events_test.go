@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Iandenh/overleash/overleash"
+)
+
+func updateEvent(t *testing.T, events ...overleash.Event) overleash.SseEvent {
+	t.Helper()
+
+	data, err := json.Marshal(overleash.Events{Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return overleash.SseEvent{Event: "unleash-updated", Data: string(data)}
+}
+
+func TestDashboardEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		event func(t *testing.T) overleash.SseEvent
+		want  []overleash.SseEvent
+	}{
+		{
+			name: "feature and override changes refresh their cards once",
+			event: func(t *testing.T) overleash.SseEvent {
+				return updateEvent(t,
+					&overleash.FeatureUpdatedEvent{Type: "feature-updated", Feature: overleash.Feature{Name: "a"}},
+					&overleash.OverrideUpdatedEvent{Type: "override-updated", Override: &overleash.Override{FeatureFlag: "a"}},
+					&overleash.OverrideRemovedEvent{Type: "override-removed", FeatureFlag: "b"},
+				)
+			},
+			want: []overleash.SseEvent{
+				{Event: dashboardFlagEvent, Data: "a"},
+				{Event: dashboardFlagEvent, Data: "b"},
+			},
+		},
+		{
+			name: "removed features refresh the list",
+			event: func(t *testing.T) overleash.SseEvent {
+				return updateEvent(t, &overleash.FeatureRemovedEvent{Type: "feature-removed", FeatureName: "a"})
+			},
+			want: []overleash.SseEvent{{Event: dashboardListEvent}},
+		},
+		{
+			name: "pausing refreshes the dashboard",
+			event: func(t *testing.T) overleash.SseEvent {
+				return updateEvent(t, &overleash.PausedChangedEvent{Type: "paused-changed", Paused: true})
+			},
+			want: []overleash.SseEvent{{Event: dashboardRefreshEvent}},
+		},
+		{
+			name: "upstream sync",
+			event: func(t *testing.T) overleash.SseEvent {
+				return updateEvent(t, &overleash.SyncedEvent{Type: "synced"})
+			},
+			want: []overleash.SseEvent{{Event: dashboardSyncEvent}},
+		},
+		{
+			name: "a later hydration refreshes the dashboard",
+			event: func(t *testing.T) overleash.SseEvent {
+				return overleash.SseEvent{Event: "unleash-connected", Data: `{"events":[]}`}
+			},
+			want: []overleash.SseEvent{{Event: dashboardRefreshEvent}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translate := dashboardEvents()
+
+			// The first hydration is the state the dashboard was rendered with.
+			if got := translate(overleash.SseEvent{Event: "unleash-connected", Data: `{"events":[]}`}); len(got) != 0 {
+				t.Fatalf("Expected the first hydration to be ignored, got %v", got)
+			}
+
+			if got := translate(tt.event(t)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/Iandenh/overleash/config"
 	"github.com/Iandenh/overleash/overleash"
 	"github.com/charmbracelet/log"
 )
@@ -15,7 +18,20 @@ type httpSubscriber struct {
 	writer               http.ResponseWriter
 	isOverleashClient    bool
 	useActiveEnvironment bool
+	projects             []string
 	send                 chan overleash.SseEvent
+
+	// policy decides what happens once an event is dropped, see
+	// config.StreamSlowSubscriber.
+	policy string
+	// overflow is set from the first dropped event until the subscriber is
+	// hydrated again; the events in between are covered by the hydration.
+	overflow atomic.Bool
+	resync   chan struct{}
+	cancel   context.CancelFunc
+
+	// translate, when set, turns an event into the events actually written.
+	translate func(e overleash.SseEvent) []overleash.SseEvent
 }
 
 func (h *httpSubscriber) UseActiveEnvironment() bool {
@@ -26,15 +42,56 @@ func (h *httpSubscriber) IsOverleashClient() bool {
 	return h.isOverleashClient
 }
 
+func (h *httpSubscriber) Projects() []string {
+	return h.projects
+}
+
 func (h *httpSubscriber) Notify(e overleash.SseEvent) {
+	if h.overflow.Load() {
+		if e.Event != "unleash-connected" {
+			streamDroppedEvents.Inc()
+			return
+		}
+
+		h.overflow.Store(false)
+	}
+
 	select {
 	case h.send <- e:
 	default:
-		log.Printf("dropping event for slow subscriber (overleash=%v)", h.isOverleashClient)
+		streamDroppedEvents.Inc()
+
+		if !h.overflow.CompareAndSwap(false, true) {
+			return
+		}
+
+		streamForcedResyncs.WithLabelValues(h.policy).Inc()
+		log.Warnf("subscriber cannot keep up, applying %s policy (overleash=%v)", h.policy, h.isOverleashClient)
+
+		if h.policy == config.SlowSubscriberDisconnect {
+			h.cancel()
+			return
+		}
+
+		select {
+		case h.resync <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drain discards the events that are still queued.
+func (h *httpSubscriber) drain() {
+	for {
+		select {
+		case <-h.send:
+		default:
+			return
+		}
 	}
 }
 
-func (h *httpSubscriber) run(ctx context.Context) {
+func (h *httpSubscriber) run(ctx context.Context, o *overleash.OverleashContext) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
@@ -48,6 +105,9 @@ func (h *httpSubscriber) run(ctx context.Context) {
 				return
 			}
 			h.flusher.Flush()
+		case <-h.resync:
+			h.drain()
+			o.ResyncStreamerSubscriber(h)
 		case e, ok := <-h.send:
 			if !ok {
 				return
@@ -57,7 +117,7 @@ func (h *httpSubscriber) run(ctx context.Context) {
 			if e.OverleashEvent && !h.isOverleashClient {
 				continue
 			}
-			if err := h.writeEvent(e); err != nil {
+			if err := h.write(e); err != nil {
 				log.Printf("subscriber write error: %v", err)
 				return
 			}
@@ -65,6 +125,20 @@ func (h *httpSubscriber) run(ctx context.Context) {
 	}
 }
 
+func (h *httpSubscriber) write(e overleash.SseEvent) error {
+	if h.translate == nil {
+		return h.writeEvent(e)
+	}
+
+	for _, translated := range h.translate(e) {
+		if err := h.writeEvent(translated); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (h *httpSubscriber) writeEvent(e overleash.SseEvent) error {
 	if e.Id != "" {
 		if _, err := fmt.Fprintf(h.writer, "id: %s\n", e.Id); err != nil {
@@ -86,8 +160,35 @@ func (h *httpSubscriber) writeEvent(e overleash.SseEvent) error {
 	return nil
 }
 
+// clientEvents drops the batches that only tell about a sync of this instance.
+// They refresh the dashboard, but a downstream Overleash has nothing to apply.
+func clientEvents(e overleash.SseEvent) []overleash.SseEvent {
+	if !e.OverleashEvent {
+		return []overleash.SseEvent{e}
+	}
+
+	var events overleash.Events
+
+	if err := json.Unmarshal([]byte(e.Data), &events); err == nil && events.OnlySynced() {
+		return nil
+	}
+
+	return []overleash.SseEvent{e}
+}
+
 func (c *Server) registerDeltaApi(s *http.ServeMux) {
 	s.HandleFunc("/api/client/streaming", func(w http.ResponseWriter, r *http.Request) {
+		env := c.featureEnvironmentFromRequest(r)
+
+		// Overleash does not authenticate clients, so a token whose project
+		// scope cannot be determined streams every project, as it gets them
+		// from /api/client/features.
+		projects, err := env.TokenProjects(r.Header.Get("Authorization"))
+
+		if err != nil {
+			log.Debugf("streaming every project: %v", err)
+		}
+
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
@@ -102,7 +203,7 @@ func (c *Server) registerDeltaApi(s *http.ServeMux) {
 
 		// Disable the server's read and write timeouts for this long-lived connection.
 		// Setting a zero-value time.Time effectively means no deadline.
-		err := rc.SetReadDeadline(time.Time{})
+		err = rc.SetReadDeadline(time.Time{})
 		if err != nil {
 			log.Printf("failed to set read deadline: %v", err)
 		}
@@ -112,25 +213,35 @@ func (c *Server) registerDeltaApi(s *http.ServeMux) {
 			log.Printf("failed to set write deadline: %v", err)
 		}
 
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
 		isOverleash := r.Header.Get("X-Overleash") == "yes"
 		subscriber := &httpSubscriber{
 			flusher:              flusher,
 			writer:               w,
 			isOverleashClient:    isOverleash,
 			useActiveEnvironment: !c.Overleash.Config.EnvFromToken,
-			send:                 make(chan overleash.SseEvent, 32),
+			projects:             projects,
+			send:                 make(chan overleash.SseEvent, overleash.StreamHistorySize+32),
+			policy:               c.Overleash.Config.StreamSlowSubscriber,
+			resync:               make(chan struct{}, 1),
+			cancel:               cancel,
+			translate:            clientEvents,
 		}
 
-		env := c.featureEnvironmentFromRequest(r)
-		env.AddStreamerSubscriber(subscriber, c.Overleash, true)
-		defer env.RemoveStreamerSubscriber(subscriber, true)
+		if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+			env.ResumeStreamerSubscriber(subscriber, c.Overleash, lastEventId)
+		} else {
+			env.AddStreamerSubscriber(subscriber, c.Overleash, true)
+		}
+		defer c.Overleash.RemoveStreamerSubscriber(subscriber)
 
-		ctx := r.Context()
-		go subscriber.run(ctx)
+		go subscriber.run(ctx, c.Overleash)
 
 		for {
 			select {
-			case <-r.Context().Done():
+			case <-ctx.Done():
 				log.Printf("SSE client disconnected (overleash=%v)", isOverleash)
 				return
 			}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/overleash"
+)
+
+func newTestSubscriber(policy string, size int) (*httpSubscriber, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &httpSubscriber{
+		send:   make(chan overleash.SseEvent, size),
+		policy: policy,
+		resync: make(chan struct{}, 1),
+		cancel: cancel,
+	}, ctx
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	subscriber, ctx := newTestSubscriber(config.SlowSubscriberDisconnect, 1)
+
+	subscriber.Notify(overleash.SseEvent{Id: "1", Event: "unleash-updated"})
+
+	if ctx.Err() != nil {
+		t.Fatal("Expected the subscriber to stay connected while it keeps up")
+	}
+
+	subscriber.Notify(overleash.SseEvent{Id: "2", Event: "unleash-updated"})
+
+	if ctx.Err() == nil {
+		t.Error("Expected the subscriber to be disconnected after dropping an event")
+	}
+}
+
+func TestSlowSubscriberIsResynced(t *testing.T) {
+	subscriber, ctx := newTestSubscriber(config.SlowSubscriberResync, 1)
+
+	subscriber.Notify(overleash.SseEvent{Id: "1", Event: "unleash-updated"})
+	subscriber.Notify(overleash.SseEvent{Id: "2", Event: "unleash-updated"})
+
+	if ctx.Err() != nil {
+		t.Fatal("Expected the subscriber to stay connected")
+	}
+
+	select {
+	case <-subscriber.resync:
+	default:
+		t.Fatal("Expected a resync to be requested")
+	}
+
+	subscriber.drain()
+
+	// Until the hydration arrives, updates are covered by it and discarded.
+	subscriber.Notify(overleash.SseEvent{Id: "3", Event: "unleash-updated"})
+
+	if len(subscriber.send) != 0 {
+		t.Fatal("Expected updates to be discarded while resyncing")
+	}
+
+	subscriber.Notify(overleash.SseEvent{Id: "3", Event: "unleash-connected"})
+	subscriber.drain()
+	subscriber.Notify(overleash.SseEvent{Id: "4", Event: "unleash-updated"})
+
+	if e := <-subscriber.send; e.Id != "4" {
+		t.Errorf("Expected updates to be delivered again after the hydration, got %v", e)
+	}
+}
+
+func TestStreamingWithoutProjectScopeSendsEveryProject(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "development.json")
+	data := `{"version":2,"features":[{"name":"alpha-flag","project":"alpha"},{"name":"beta-flag","project":"beta"}]}`
+
+	if err := os.WriteFile(fixture, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Offline:  fixture,
+		Storage:  "null",
+		Reload:   "0",
+		Streamer: true,
+	}
+
+	s := &Server{Overleash: overleash.NewOverleash(cfg)}
+	s.Overleash.Start(t.Context())
+
+	mux := http.NewServeMux()
+	s.registerDeltaApi(mux)
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// In offline mode a multi-project token cannot be validated either.
+	for _, token := range []string{"", "secret", "[]:development.abc"} {
+		t.Run(token, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/client/streaming", nil)
+			req.Header.Set("Authorization", token)
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("Expected the stream to be accepted, got %d", res.StatusCode)
+			}
+
+			scanner := bufio.NewScanner(res.Body)
+
+			for scanner.Scan() {
+				line := scanner.Text()
+
+				if !strings.HasPrefix(line, "data: ") {
+					continue
+				}
+
+				if !strings.Contains(line, "alpha-flag") || !strings.Contains(line, "beta-flag") {
+					t.Errorf("Expected the flags of every project, got %s", line)
+				}
+
+				return
+			}
+
+			t.Fatal("Expected a hydration")
+		})
+	}
+}
+
+func TestClientEventsDropSyncedBatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		event overleash.SseEvent
+		want  int
+	}{
+		{name: "synced", event: overleash.SseEvent{Event: "unleash-updated", Data: `{"events":[{"type":"synced","eventId":2,"lastSync":"2026-01-01T00:00:00Z"}]}`, OverleashEvent: true}, want: 0},
+		{name: "override", event: overleash.SseEvent{Event: "unleash-updated", Data: `{"events":[{"type":"override-removed","eventId":2,"featureFlag":"a"}]}`, OverleashEvent: true}, want: 1},
+		{name: "feature", event: overleash.SseEvent{Event: "unleash-updated", Data: `{"events":[{"type":"feature-removed","eventId":2,"featureName":"a","project":"default"}]}`}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientEvents(tt.event); len(got) != tt.want {
+				t.Errorf("Expected %d events, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -8,7 +8,8 @@ import (
 
 func (c *Server) registerEdgeApi(s *http.ServeMux) {
 	s.Handle("POST /edge/validate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		p := proxy.New(c.Overleash.Upstream())
+		env := c.featureEnvironmentFromRequest(r)
+		p := proxy.New(env.Upstream(), env.UpstreamHTTPClient())
 
 		err := p.ProxyRequest(w, r)
 
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Iandenh/overleash/overleash"
+)
+
+type acceptedEncoding struct {
+	name string
+	q    float64
+}
+
+// negotiateEncoding picks the pre-compressed encoding to serve for an
+// Accept-Encoding header. It returns "" when the response should be sent
+// uncompressed.
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make([]acceptedEncoding, 0)
+	// named are the encodings listed explicitly, including those refused with
+	// q=0; "*" only stands for the others (RFC 9110, section 12.5.3).
+	named := make(map[string]bool)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+
+			if err != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		named[name] = true
+		accepted = append(accepted, acceptedEncoding{name: name, q: q})
+	}
+
+	best := ""
+	bestQ := 0.0
+
+	for _, encoding := range accepted {
+		if encoding.q <= 0 {
+			continue
+		}
+
+		candidates := []string{encoding.name}
+
+		if encoding.name == "*" {
+			candidates = slices.DeleteFunc(slices.Clone(overleash.SupportedEncodings), func(name string) bool {
+				return named[name]
+			})
+		}
+
+		for _, candidate := range candidates {
+			rank := slices.Index(overleash.SupportedEncodings, candidate)
+
+			if rank == -1 {
+				continue
+			}
+
+			if encoding.q > bestQ || (encoding.q == bestQ && rank < slices.Index(overleash.SupportedEncodings, best)) {
+				best = candidate
+				bestQ = encoding.q
+			}
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,32 @@
+package server
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "no header", acceptEncoding: "", want: ""},
+		{name: "identity only", acceptEncoding: "identity", want: ""},
+		{name: "single encoding", acceptEncoding: "gzip", want: "gzip"},
+		{name: "server preference on a tie", acceptEncoding: "gzip, deflate, br, zstd", want: "br"},
+		{name: "client preference by q", acceptEncoding: "br;q=0.5, gzip;q=0.9", want: "gzip"},
+		{name: "rejected encoding", acceptEncoding: "br;q=0, gzip", want: "gzip"},
+		{name: "wildcard", acceptEncoding: "*", want: "br"},
+		{name: "wildcard excludes rejected encoding", acceptEncoding: "br;q=0, *", want: "zstd"},
+		{name: "wildcard excludes listed encoding", acceptEncoding: "br;q=0.1, *;q=0.5", want: "zstd"},
+		{name: "wildcard rejected", acceptEncoding: "gzip, *;q=0", want: "gzip"},
+		{name: "case insensitive", acceptEncoding: "GZIP", want: "gzip"},
+		{name: "unsupported only", acceptEncoding: "deflate, compress", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding); got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,15 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/Iandenh/overleash/overleash"
 	"github.com/Iandenh/overleash/unleashengine"
-	"github.com/charmbracelet/log"
 )
 
 func (c *Server) registerFrontendApi(s *http.ServeMux) {
@@ -16,72 +18,37 @@ func (c *Server) registerFrontendApi(s *http.ServeMux) {
 		c.Overleash.LockMutex.RLock()
 		defer c.Overleash.LockMutex.RUnlock()
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
 		ctx := createContextFromGetRequest(r)
+		c.applyDefaultContext(r, ctx)
 
-		result, err := c.featureEnvironmentFromRequest(r).Engine().ResolveAll(ctx, false)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-
-			return
-		}
-
-		resultJson, _ := json.Marshal(result)
-
-		w.Write(resultJson)
+		c.writeEvaluation(w, r, ctx, false)
 	}))
 
 	s.Handle("POST /api/frontend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Overleash.LockMutex.RLock()
 		defer c.Overleash.LockMutex.RUnlock()
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
 		ctx, err := createContextFromPostRequest(r)
 
 		if err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusBadRequest)
+			http.Error(w, "Invalid context: "+err.Error(), http.StatusBadRequest)
 
 			return
 		}
 
-		result, err := c.featureEnvironmentFromRequest(r).Engine().ResolveAll(ctx, false)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-
-			return
-		}
+		c.applyDefaultContext(r, ctx)
 
-		resultJson, _ := json.Marshal(result)
-		w.Write(resultJson)
+		c.writeEvaluation(w, r, ctx, false)
 	}))
 
 	s.Handle("GET /api/frontend/all", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Overleash.LockMutex.RLock()
 		defer c.Overleash.LockMutex.RUnlock()
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
 		ctx := createContextFromGetRequest(r)
+		c.applyDefaultContext(r, ctx)
 
-		result, err := c.featureEnvironmentFromRequest(r).Engine().ResolveAll(ctx, true)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-
-			return
-		}
-
-		resultJson, _ := json.Marshal(result)
-
-		w.Write(resultJson)
+		c.writeEvaluation(w, r, ctx, true)
 	}))
 
 	s.Handle("POST /api/frontend/features/{featureName}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,13 +60,24 @@ func (c *Server) registerFrontendApi(s *http.ServeMux) {
 		ctx, err := createContextFromPostRequest(r)
 
 		if err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusBadRequest)
+			http.Error(w, "Invalid context: "+err.Error(), http.StatusBadRequest)
 
 			return
 		}
 
-		result, err := c.featureEnvironmentFromRequest(r).Engine().Resolve(ctx, featureName)
+		c.applyDefaultContext(r, ctx)
+
+		engine, release, err := c.engineFromRequest(r)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		defer release()
+
+		result, err := engine.Resolve(ctx, featureName)
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -122,8 +100,19 @@ func (c *Server) registerFrontendApi(s *http.ServeMux) {
 		featureName := r.PathValue("featureName")
 
 		ctx := createContextFromGetRequest(r)
+		c.applyDefaultContext(r, ctx)
 
-		result, err := c.featureEnvironmentFromRequest(r).Engine().Resolve(ctx, featureName)
+		engine, release, err := c.engineFromRequest(r)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		defer release()
+
+		result, err := engine.Resolve(ctx, featureName)
 
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -162,6 +151,56 @@ func (c *Server) registerFrontendApi(s *http.ServeMux) {
 	}))
 }
 
+// writeEvaluation evaluates all toggles for ctx and writes the result, or a
+// 304 when the client already holds it. Evaluations are served from the
+// environment's cache unless the request carries overrides.
+func (c *Server) writeEvaluation(w http.ResponseWriter, r *http.Request, ctx *unleashengine.Context, includeAll bool) {
+	env := c.featureEnvironmentFromRequest(r)
+
+	state, err := c.requestState(r, env)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	var evaluation *overleash.Evaluation
+
+	if state != nil {
+		defer state.Close()
+
+		evaluation, err = state.ResolveAll(ctx, includeAll)
+	} else {
+		var hit bool
+
+		evaluation, hit, err = env.ResolveAll(ctx, includeAll)
+
+		if err == nil {
+			recordEvaluationCache(hit)
+		}
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf("W/\"%s\"", evaluation.Etag))
+
+	if matchesEtag(r, evaluation.Etag) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(evaluation.Json)
+}
+
 func createContextFromGetRequest(r *http.Request) *unleashengine.Context {
 	properties := make(map[string]any)
 
@@ -204,6 +243,11 @@ func createContextFromGetRequest(r *http.Request) *unleashengine.Context {
 	return ctx
 }
 
+// createContextFromPostRequest builds the evaluation context from a JSON body.
+//
+// The context may be sent as is, or wrapped in a "context" object as some SDKs
+// do. Numbers and booleans are accepted wherever a string is expected and are
+// stringified the way Unleash SDKs do; anything else is rejected.
 func createContextFromPostRequest(r *http.Request) (*unleashengine.Context, error) {
 	m := map[string]any{}
 
@@ -211,47 +255,112 @@ func createContextFromPostRequest(r *http.Request) (*unleashengine.Context, erro
 
 	err := decoder.Decode(&m)
 
+	if errors.Is(err, io.EOF) {
+		return &unleashengine.Context{Properties: map[string]string{}}, nil
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("body must be a JSON object: %w", err)
+	}
+
+	if wrapped, ok := m["context"]; ok {
+		inner, ok := wrapped.(map[string]any)
+
+		if !ok {
+			return nil, errors.New("context must be an object")
+		}
+
+		m = inner
 	}
-	properties := make(map[string]any)
 
 	ctx := &unleashengine.Context{}
+	properties := make(map[string]string)
+	extra := make(map[string]string)
 
 	for k, v := range m {
+		var err error
+
 		switch k {
 		case "userId":
-			ctx.UserId = getData(v)
+			ctx.UserId, err = contextValue(k, v)
 		case "environment":
-			ctx.Environment = getData(v)
+			ctx.Environment, err = contextValue(k, v)
 		case "appName":
-			ctx.AppName = getData(v)
+			ctx.AppName, err = contextValue(k, v)
 		case "sessionId":
-			ctx.SessionId = getData(v)
+			ctx.SessionId, err = contextValue(k, v)
 		case "currentTime":
-			ctx.CurrentTime = getData(v)
+			ctx.CurrentTime, err = contextValue(k, v)
 		case "remoteAddress":
-			ctx.RemoteAddress = getData(v)
+			ctx.RemoteAddress, err = contextValue(k, v)
 		case "properties":
-			properties = v.(map[string]any)
+			err = contextProperties(v, properties)
 		default:
-			properties[k] = v
+			// Top-level fields that are not part of the context are treated as
+			// properties when they hold a plain value, and ignored otherwise.
+			if value := getData(v); value != nil {
+				extra[k] = *value
+			}
+		}
+
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	clean := make(map[string]string)
-	for k, v := range properties {
-		switch val := v.(type) {
-		case string:
-			clean[k] = val
+	// Explicit properties win over top-level fields of the same name.
+	for k, v := range extra {
+		if _, ok := properties[k]; !ok {
+			properties[k] = v
 		}
 	}
 
-	ctx.Properties = clean
+	ctx.Properties = properties
 
 	return ctx, nil
 }
 
+func contextProperties(data any, properties map[string]string) error {
+	if data == nil {
+		return nil
+	}
+
+	m, ok := data.(map[string]any)
+
+	if !ok {
+		return errors.New("properties must be an object")
+	}
+
+	for k, v := range m {
+		value, err := contextValue("properties."+k, v)
+
+		if err != nil {
+			return err
+		}
+
+		if value != nil {
+			properties[k] = *value
+		}
+	}
+
+	return nil
+}
+
+// contextValue converts a JSON value to a context value. Null means unset.
+func contextValue(name string, data any) (*string, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	value := getData(data)
+
+	if value == nil {
+		return nil, fmt.Errorf("%s must be a string, number or boolean", name)
+	}
+
+	return value, nil
+}
+
 func getQuery(r *http.Request, name string) *string {
 	if !r.URL.Query().Has(name) {
 		return nil
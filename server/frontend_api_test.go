@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateContextFromPostRequest(t *testing.T) {
+	type want struct {
+		userId      string
+		appName     string
+		currentTime string
+		properties  map[string]string
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    want
+		wantErr string
+	}{
+		{
+			name: "plain context",
+			body: `{"userId": "123", "appName": "web", "properties": {"plan": "pro"}}`,
+			want: want{userId: "123", appName: "web", properties: map[string]string{"plan": "pro"}},
+		},
+		{
+			name: "numbers and booleans are stringified",
+			body: `{"userId": 42, "properties": {"age": 31, "ratio": 0.5, "beta": true, "big": 10000000000}}`,
+			want: want{userId: "42", properties: map[string]string{"age": "31", "ratio": "0.5", "beta": "true", "big": "10000000000"}},
+		},
+		{
+			name: "null values are unset",
+			body: `{"userId": null, "properties": {"plan": null}}`,
+			want: want{properties: map[string]string{}},
+		},
+		{
+			name: "nested context wrapper",
+			body: `{"context": {"userId": "123", "currentTime": "2026-01-01T00:00:00Z", "properties": {"age": 31}}}`,
+			want: want{userId: "123", currentTime: "2026-01-01T00:00:00Z", properties: map[string]string{"age": "31"}},
+		},
+		{
+			name: "top-level fields become properties",
+			body: `{"userId": "123", "age": 31, "plan": "pro", "ignored": {"nested": true}}`,
+			want: want{userId: "123", properties: map[string]string{"age": "31", "plan": "pro"}},
+		},
+		{
+			name: "explicit properties win over top-level fields",
+			body: `{"plan": "free", "properties": {"plan": "pro"}}`,
+			want: want{properties: map[string]string{"plan": "pro"}},
+		},
+		{
+			name: "empty body",
+			body: ``,
+			want: want{properties: map[string]string{}},
+		},
+		{
+			name:    "not json",
+			body:    `userId=123`,
+			wantErr: "body must be a JSON object",
+		},
+		{
+			name:    "not an object",
+			body:    `["userId"]`,
+			wantErr: "body must be a JSON object",
+		},
+		{
+			name:    "properties is not an object",
+			body:    `{"properties": "plan=pro"}`,
+			wantErr: "properties must be an object",
+		},
+		{
+			name:    "context wrapper is not an object",
+			body:    `{"context": "123"}`,
+			wantErr: "context must be an object",
+		},
+		{
+			name:    "nested property value",
+			body:    `{"properties": {"plan": {"name": "pro"}}}`,
+			wantErr: "properties.plan must be a string, number or boolean",
+		},
+		{
+			name:    "array as context field",
+			body:    `{"userId": ["123"]}`,
+			wantErr: "userId must be a string, number or boolean",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/frontend", strings.NewReader(tt.body))
+
+			ctx, err := createContextFromPostRequest(r)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := ctx.GetUserId(); got != tt.want.userId {
+				t.Errorf("userId = %q, want %q", got, tt.want.userId)
+			}
+			if got := ctx.GetAppName(); got != tt.want.appName {
+				t.Errorf("appName = %q, want %q", got, tt.want.appName)
+			}
+			if got := ctx.GetCurrentTime(); got != tt.want.currentTime {
+				t.Errorf("currentTime = %q, want %q", got, tt.want.currentTime)
+			}
+			if !reflect.DeepEqual(ctx.Properties, tt.want.properties) {
+				t.Errorf("properties = %v, want %v", ctx.Properties, tt.want.properties)
+			}
+		})
+	}
+}
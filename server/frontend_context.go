@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/unleashengine"
+)
+
+// applyDefaultContext fills in the context values the client did not send.
+// Configured defaults are taken from the frontend token, then the environment,
+// then "*"; the remote address is derived from the request.
+func (c *Server) applyDefaultContext(r *http.Request, ctx *unleashengine.Context) {
+	keys := []string{
+		r.Header.Get("Authorization"),
+		c.featureEnvironmentFromRequest(r).Environment(),
+		"*",
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if defaults, ok := c.defaultContexts[key]; ok {
+			mergeDefaultContext(ctx, defaults)
+		}
+	}
+
+	if ctx.RemoteAddress == nil {
+		if addr := c.remoteAddress(r); addr != "" {
+			ctx.RemoteAddress = &addr
+		}
+	}
+}
+
+func mergeDefaultContext(ctx *unleashengine.Context, defaults config.DefaultContext) {
+	ctx.UserId = defaultValue(ctx.UserId, defaults.UserId)
+	ctx.SessionId = defaultValue(ctx.SessionId, defaults.SessionId)
+	ctx.Environment = defaultValue(ctx.Environment, defaults.Environment)
+	ctx.AppName = defaultValue(ctx.AppName, defaults.AppName)
+	ctx.CurrentTime = defaultValue(ctx.CurrentTime, defaults.CurrentTime)
+	ctx.RemoteAddress = defaultValue(ctx.RemoteAddress, defaults.RemoteAddress)
+
+	if len(defaults.Properties) == 0 {
+		return
+	}
+
+	if ctx.Properties == nil {
+		ctx.Properties = make(map[string]string, len(defaults.Properties))
+	}
+
+	for k, v := range defaults.Properties {
+		if _, ok := ctx.Properties[k]; !ok {
+			ctx.Properties[k] = v
+		}
+	}
+}
+
+func defaultValue(value, fallback *string) *string {
+	if value != nil || fallback == nil {
+		return value
+	}
+
+	v := *fallback
+
+	return &v
+}
+
+// remoteAddress returns the address of the client. X-Forwarded-For is only
+// followed through proxies that are configured as trusted, starting from the
+// connection itself, so a client cannot spoof its address.
+func (c *Server) remoteAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+
+	if err != nil {
+		return host
+	}
+
+	addr = addr.Unmap()
+
+	if !c.isTrustedProxy(addr) {
+		return addr.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+
+		if err != nil {
+			break
+		}
+
+		addr = hop.Unmap()
+
+		if !c.isTrustedProxy(addr) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+func (c *Server) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/unleashengine"
+)
+
+func TestMergeDefaultContextKeepsClientValues(t *testing.T) {
+	clientUser := "client"
+	defaultUser := "default"
+	defaultApp := "web"
+
+	ctx := &unleashengine.Context{UserId: &clientUser, Properties: map[string]string{"plan": "pro"}}
+
+	mergeDefaultContext(ctx, config.DefaultContext{
+		UserId:     &defaultUser,
+		AppName:    &defaultApp,
+		Properties: map[string]string{"plan": "free", "region": "eu"},
+	})
+
+	if ctx.GetUserId() != "client" {
+		t.Errorf("userId = %q, want the client value", ctx.GetUserId())
+	}
+	if ctx.GetAppName() != "web" {
+		t.Errorf("appName = %q, want the default value", ctx.GetAppName())
+	}
+
+	want := map[string]string{"plan": "pro", "region": "eu"}
+	if !reflect.DeepEqual(ctx.Properties, want) {
+		t.Errorf("properties = %v, want %v", ctx.Properties, want)
+	}
+
+	// The default must be copied, not shared between requests.
+	*ctx.AppName = "changed"
+	if defaultApp != "web" {
+		t.Error("Expected the default value to be copied")
+	}
+}
+
+func TestRemoteAddress(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		trusted      []netip.Prefix
+		wantAddr     string
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "203.0.113.7:1234",
+			wantAddr:   "203.0.113.7",
+		},
+		{
+			name:         "forwarded header from untrusted peer is ignored",
+			remoteAddr:   "203.0.113.7:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			trusted:      trusted,
+			wantAddr:     "203.0.113.7",
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1"},
+			trusted:      trusted,
+			wantAddr:     "198.51.100.1",
+		},
+		{
+			name:         "chain of trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"192.0.2.9, 198.51.100.1", "10.0.0.2"},
+			trusted:      trusted,
+			wantAddr:     "198.51.100.1",
+		},
+		{
+			name:         "malformed hop stops the walk",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1, unknown"},
+			trusted:      trusted,
+			wantAddr:     "10.0.0.1",
+		},
+		{
+			name:       "ipv4 mapped ipv6",
+			remoteAddr: "[::ffff:203.0.113.7]:1234",
+			wantAddr:   "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: tt.trusted}
+
+			r := httptest.NewRequest("GET", "/api/frontend", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := s.remoteAddress(r); got != tt.wantAddr {
+				t.Errorf("remoteAddress = %q, want %q", got, tt.wantAddr)
+			}
+		})
+	}
+}
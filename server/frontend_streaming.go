@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Iandenh/overleash/overleash"
+	"github.com/Iandenh/overleash/unleashengine"
+	"github.com/charmbracelet/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// frontendSubscriber is subscribed to the streamer of an environment on behalf
+// of a frontend stream. The events themselves are not forwarded; any of them
+// means the environment was recompiled and the context must be re-evaluated.
+type frontendSubscriber struct {
+	useActiveEnvironment bool
+	changed              chan struct{}
+}
+
+func (f *frontendSubscriber) UseActiveEnvironment() bool {
+	return f.useActiveEnvironment
+}
+
+func (f *frontendSubscriber) IsOverleashClient() bool {
+	// Overrides change the evaluation, so their events are wanted too.
+	return true
+}
+
+func (f *frontendSubscriber) Projects() []string {
+	return nil
+}
+
+func (f *frontendSubscriber) Notify(e overleash.SseEvent) {
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+}
+
+// changedToggles returns the toggles of current whose evaluation differs from
+// previous. Toggles that are no longer returned are reported as disabled.
+func changedToggles(previous, current []*unleashengine.EvaluatedToggle) []*unleashengine.EvaluatedToggle {
+	before := make(map[string]*unleashengine.EvaluatedToggle, len(previous))
+
+	for _, toggle := range previous {
+		before[toggle.Name] = toggle
+	}
+
+	changed := make([]*unleashengine.EvaluatedToggle, 0)
+	seen := make(map[string]bool, len(current))
+
+	for _, toggle := range current {
+		seen[toggle.Name] = true
+
+		if old, ok := before[toggle.Name]; !ok || !proto.Equal(old, toggle) {
+			changed = append(changed, toggle)
+		}
+	}
+
+	for _, toggle := range previous {
+		if seen[toggle.Name] {
+			continue
+		}
+
+		changed = append(changed, &unleashengine.EvaluatedToggle{
+			Name:           toggle.Name,
+			Enabled:        false,
+			Variant:        &unleashengine.EvaluatedVariant{Name: "disabled", Enabled: false},
+			ImpressionData: toggle.ImpressionData,
+		})
+	}
+
+	return changed
+}
+
+func (c *Server) registerFrontendStreaming(s *http.ServeMux) {
+	s.HandleFunc("GET /api/frontend/streaming", func(w http.ResponseWriter, r *http.Request) {
+		env := c.featureEnvironmentFromRequest(r)
+
+		if env.Streamer == nil {
+			http.Error(w, "Streaming unsupported", http.StatusNotFound)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := createContextFromGetRequest(r)
+		c.applyDefaultContext(r, ctx)
+
+		subscriber := &frontendSubscriber{
+			useActiveEnvironment: !c.Overleash.Config.EnvFromToken,
+			changed:              make(chan struct{}, 1),
+		}
+
+		evaluate := func() ([]*unleashengine.EvaluatedToggle, error) {
+			c.Overleash.LockMutex.RLock()
+			defer c.Overleash.LockMutex.RUnlock()
+
+			fe := env
+			if subscriber.useActiveEnvironment {
+				fe = c.Overleash.ActiveFeatureEnvironment()
+			}
+
+			evaluation, hit, err := fe.ResolveAll(ctx, false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			recordEvaluationCache(hit)
+
+			return evaluation.Toggles.GetToggles(), nil
+		}
+
+		write := func(event string, toggles []*unleashengine.EvaluatedToggle) error {
+			data, err := json.Marshal(&unleashengine.EvaluatedToggleList{Toggles: toggles})
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+				return err
+			}
+
+			flusher.Flush()
+
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		rc := http.NewResponseController(w)
+
+		if err := rc.SetReadDeadline(time.Time{}); err != nil {
+			log.Printf("failed to set read deadline: %v", err)
+		}
+
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("failed to set write deadline: %v", err)
+		}
+
+		env.AddStreamerSubscriber(subscriber, c.Overleash, true)
+		defer c.Overleash.RemoveStreamerSubscriber(subscriber)
+
+		toggles, err := evaluate()
+
+		if err != nil {
+			log.Errorf("Unable to evaluate the frontend stream: %v", err)
+			return
+		}
+
+		if err := write("unleash-connected", toggles); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if _, err := fmt.Fprintf(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-subscriber.changed:
+				current, err := evaluate()
+
+				if err != nil {
+					log.Errorf("Unable to evaluate the frontend stream: %v", err)
+					continue
+				}
+
+				changed := changedToggles(toggles, current)
+				toggles = current
+
+				if len(changed) == 0 {
+					continue
+				}
+
+				if err := write("unleash-updated", changed); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Iandenh/overleash/unleashengine"
+	"google.golang.org/protobuf/proto"
+)
+
+func toggle(name string, enabled bool, variant string) *unleashengine.EvaluatedToggle {
+	return &unleashengine.EvaluatedToggle{
+		Name:    name,
+		Enabled: enabled,
+		Variant: &unleashengine.EvaluatedVariant{Name: variant, Enabled: variant != "disabled"},
+	}
+}
+
+func TestChangedToggles(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []*unleashengine.EvaluatedToggle
+		current  []*unleashengine.EvaluatedToggle
+		want     []*unleashengine.EvaluatedToggle
+	}{
+		{
+			name:     "nothing changed",
+			previous: []*unleashengine.EvaluatedToggle{toggle("a", true, "disabled")},
+			current:  []*unleashengine.EvaluatedToggle{toggle("a", true, "disabled")},
+			want:     []*unleashengine.EvaluatedToggle{},
+		},
+		{
+			name:     "a new toggle",
+			previous: []*unleashengine.EvaluatedToggle{toggle("a", true, "disabled")},
+			current:  []*unleashengine.EvaluatedToggle{toggle("a", true, "disabled"), toggle("b", true, "disabled")},
+			want:     []*unleashengine.EvaluatedToggle{toggle("b", true, "disabled")},
+		},
+		{
+			name:     "a different variant",
+			previous: []*unleashengine.EvaluatedToggle{toggle("a", true, "blue"), toggle("b", true, "disabled")},
+			current:  []*unleashengine.EvaluatedToggle{toggle("a", true, "green"), toggle("b", true, "disabled")},
+			want:     []*unleashengine.EvaluatedToggle{toggle("a", true, "green")},
+		},
+		{
+			name:     "a toggle that is no longer enabled",
+			previous: []*unleashengine.EvaluatedToggle{toggle("a", true, "blue"), toggle("b", true, "disabled")},
+			current:  []*unleashengine.EvaluatedToggle{toggle("b", true, "disabled")},
+			want:     []*unleashengine.EvaluatedToggle{toggle("a", false, "disabled")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedToggles(tt.previous, tt.current)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+
+			for i := range got {
+				if !proto.Equal(got[i], tt.want[i]) {
+					t.Errorf("Expected %v at %d, got %v", tt.want[i], i, got[i])
+				}
+			}
+		})
+	}
+}
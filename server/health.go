@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Iandenh/overleash/overleash"
+	"github.com/charmbracelet/log"
+)
+
+// storagePingTimeout bounds the storage check of the detailed health.
+const storagePingTimeout = 2 * time.Second
+
+// The health endpoints need no authentication and are served outside the base
+// path, so their responses leave out upstream URLs and error texts, which can
+// name internal hosts or contain response bodies of a gateway. Those are only
+// logged.
+type healthResponse struct {
+	// Status is "ok", or "degraded" while a stream is down or an environment
+	// is stale.
+	Status string `json:"status"`
+	// Stale lists the environments that did not sync within --stale_after.
+	Stale []string `json:"stale,omitempty"`
+	// Streams is the upstream delta stream of every remote in delta mode,
+	// keyed by environment.
+	Streams map[string]streamHealth `json:"streams,omitempty"`
+}
+
+type streamHealth struct {
+	State     overleash.StreamState `json:"state"`
+	Polling   bool                  `json:"polling"`
+	Since     time.Time             `json:"since"`
+	LastEvent *time.Time            `json:"lastEvent,omitempty"`
+}
+
+type readyResponse struct {
+	// Status is "ready" once every environment has flags to serve.
+	Status string `json:"status"`
+	// Waiting lists the environments without flags yet.
+	Waiting []string `json:"waiting,omitempty"`
+}
+
+type detailedHealthResponse struct {
+	// Status is "ok", or "degraded" when any check below is not.
+	Status       string                       `json:"status"`
+	Ready        bool                         `json:"ready"`
+	Storage      storageHealth                `json:"storage"`
+	Environments map[string]environmentHealth `json:"environments"`
+}
+
+type storageHealth struct {
+	Backend string `json:"backend"`
+	// Status is "ok" or "unavailable".
+	Status string `json:"status"`
+}
+
+type environmentHealth struct {
+	Ready bool `json:"ready"`
+	// Upstream is nil for the fixtures of offline mode.
+	Upstream    *upstreamHealth `json:"upstream,omitempty"`
+	Sync        syncHealth      `json:"sync"`
+	Engine      engineHealth    `json:"engine"`
+	Stream      *streamHealth   `json:"stream,omitempty"`
+	Subscribers int             `json:"subscribers"`
+}
+
+type upstreamHealth struct {
+	// Serving is the position of the upstream serving the remote among its
+	// Upstreams, 0 being the preferred one.
+	Serving   int                    `json:"serving"`
+	Upstreams int                    `json:"upstreams"`
+	Reachable bool                   `json:"reachable"`
+	Circuit   overleash.CircuitState `json:"circuit"`
+	Failures  int                    `json:"failures"`
+	RetryAt   *time.Time             `json:"retryAt,omitempty"`
+}
+
+type syncHealth struct {
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	Revision    string     `json:"revision,omitempty"`
+	Stale       bool       `json:"stale"`
+	Restored    bool       `json:"restored"`
+	Failing     bool       `json:"failing"`
+}
+
+type engineHealth struct {
+	// State is "ok", "failing" when the engine rejected the last state, or
+	// "disabled" without the frontend API.
+	State string `json:"state"`
+}
+
+func (c *Server) health() healthResponse {
+	health := healthResponse{Status: "ok"}
+
+	for _, env := range c.Overleash.FeatureEnvironments() {
+		if env.SyncStatus().Stale {
+			health.Status = "degraded"
+			health.Stale = append(health.Stale, env.Environment())
+		}
+
+		status, ok := env.StreamStatus()
+
+		if !ok {
+			continue
+		}
+
+		if health.Streams == nil {
+			health.Streams = make(map[string]streamHealth)
+		}
+
+		if status.Polling() {
+			health.Status = "degraded"
+		}
+
+		health.Streams[env.Environment()] = newStreamHealth(status)
+	}
+
+	return health
+}
+
+func newStreamHealth(status overleash.StreamStatus) streamHealth {
+	stream := streamHealth{
+		State:   status.State,
+		Polling: status.Polling(),
+		Since:   status.Since,
+	}
+
+	if !status.LastEvent.IsZero() {
+		stream.LastEvent = &status.LastEvent
+	}
+
+	return stream
+}
+
+func (c *Server) ready() readyResponse {
+	ready := readyResponse{Status: "ready"}
+
+	for _, env := range c.Overleash.FeatureEnvironments() {
+		if !env.SyncStatus().HasData() {
+			ready.Status = "not ready"
+			ready.Waiting = append(ready.Waiting, env.Environment())
+		}
+	}
+
+	return ready
+}
+
+func (c *Server) detailedHealth(ctx context.Context) detailedHealthResponse {
+	health := detailedHealthResponse{
+		Status:       "ok",
+		Ready:        true,
+		Storage:      storageHealth{Backend: c.Overleash.Config.Storage, Status: "ok"},
+		Environments: make(map[string]environmentHealth),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storagePingTimeout)
+	defer cancel()
+
+	if err := c.Overleash.PingStorage(ctx); err != nil {
+		health.Status = "degraded"
+		health.Storage.Status = "unavailable"
+		log.Warnf("Storage backend %s is unavailable: %v", health.Storage.Backend, err)
+	}
+
+	for _, env := range c.Overleash.FeatureEnvironments() {
+		envHealth := newEnvironmentHealth(env)
+
+		if !envHealth.Ready {
+			health.Ready = false
+		}
+
+		if !envHealth.healthy() {
+			health.Status = "degraded"
+		}
+
+		health.Environments[env.Environment()] = envHealth
+	}
+
+	return health
+}
+
+func newEnvironmentHealth(env *overleash.FeatureEnvironment) environmentHealth {
+	status := env.SyncStatus()
+
+	health := environmentHealth{
+		Ready:  status.HasData(),
+		Sync:   newSyncHealth(status),
+		Engine: engineHealth{State: "disabled"},
+	}
+
+	stream, streaming := env.StreamStatus()
+
+	if streaming {
+		s := newStreamHealth(stream)
+		health.Stream = &s
+	}
+
+	if env.Fixture() == "" {
+		upstream := env.UpstreamStatus()
+
+		health.Upstream = &upstreamHealth{
+			Serving:   slices.Index(env.Upstreams(), env.Upstream()),
+			Upstreams: len(env.Upstreams()),
+			// A connected stream proves the upstream reachable; otherwise the
+			// last sync has to have succeeded.
+			Reachable: (streaming && stream.State == overleash.StreamConnected) || (!status.LastSuccess.IsZero() && !status.Failing()),
+			Circuit:   upstream.State,
+			Failures:  upstream.Failures,
+		}
+
+		if !upstream.RetryAt.IsZero() {
+			health.Upstream.RetryAt = &upstream.RetryAt
+		}
+	}
+
+	if env.Engine() != nil {
+		health.Engine.State = "ok"
+
+		if env.EngineError() != nil {
+			health.Engine.State = "failing"
+		}
+	}
+
+	if env.Streamer != nil {
+		health.Subscribers = env.Streamer.Subscribers()
+	}
+
+	return health
+}
+
+func newSyncHealth(status overleash.SyncStatus) syncHealth {
+	health := syncHealth{
+		Revision: status.Revision,
+		Stale:    status.Stale,
+		Restored: status.Restored,
+		Failing:  status.Failing(),
+	}
+
+	if !status.LastAttempt.IsZero() {
+		health.LastAttempt = &status.LastAttempt
+	}
+
+	if !status.LastSuccess.IsZero() {
+		health.LastSuccess = &status.LastSuccess
+	}
+
+	return health
+}
+
+// healthy reports whether the environment serves up-to-date flags from a
+// reachable upstream.
+func (h environmentHealth) healthy() bool {
+	if !h.Ready || h.Sync.Stale || h.Engine.State == "failing" {
+		return false
+	}
+
+	if h.Upstream != nil && !h.Upstream.Reachable {
+		return false
+	}
+
+	return h.Stream == nil || !h.Stream.Polling
+}
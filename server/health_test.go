@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Iandenh/overleash/config"
+	"github.com/Iandenh/overleash/overleash"
+)
+
+func TestHealthReportsStreamsOfDeltaRemotes(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Remotes:  `[{"token": "*:development.abc"}, {"token": "*:production.abc", "delta": true}]`,
+		Storage:  "null",
+		Reload:   "0",
+	}
+
+	s := &Server{Overleash: overleash.NewOverleash(cfg)}
+
+	health := s.health()
+
+	if health.Status != "ok" || len(health.Streams) != 1 {
+		t.Fatalf("Expected a healthy response with one stream, got %+v", health)
+	}
+
+	stream, ok := health.Streams["production"]
+	if !ok || stream.State != overleash.StreamConnecting || stream.Polling {
+		t.Errorf("Expected the production stream to be connecting, got %+v", stream)
+	}
+}
+
+func TestHealthIsDegradedByStaleEnvironments(t *testing.T) {
+	cfg := &config.Config{
+		Upstream:   "http://example.com",
+		Token:      "*:development.abc",
+		Storage:    "null",
+		Reload:     "0",
+		StaleAfter: time.Nanosecond,
+	}
+
+	s := &Server{Overleash: overleash.NewOverleash(cfg)}
+
+	time.Sleep(time.Millisecond)
+
+	health := s.health()
+
+	if health.Status != "degraded" || len(health.Stale) != 1 || health.Stale[0] != "development" {
+		t.Errorf("Expected the development environment to be stale, got %+v", health)
+	}
+}
+
+func TestReadyWaitsForEveryEnvironment(t *testing.T) {
+	cfg := &config.Config{
+		Upstream: "http://example.com",
+		Token:    "*:development.abc,*:production.abc",
+		Storage:  "null",
+		Reload:   "0",
+	}
+
+	s := &Server{Overleash: overleash.NewOverleash(cfg)}
+
+	ready := s.ready()
+
+	if ready.Status != "not ready" || len(ready.Waiting) != 2 {
+		t.Errorf("Expected both environments to be waited for, got %+v", ready)
+	}
+}
+
+func TestDetailedHealthReportsEnvironments(t *testing.T) {
+	cfg := &config.Config{
+		Upstream:       "http://example.com",
+		Token:          "*:development.abc",
+		Storage:        "null",
+		Reload:         "0",
+		EnableFrontend: true,
+	}
+
+	s := &Server{Overleash: overleash.NewOverleash(cfg)}
+
+	health := s.detailedHealth(context.Background())
+
+	if health.Status != "degraded" || health.Ready || health.Storage.Status != "ok" {
+		t.Fatalf("Expected an instance without flags to be degraded, got %+v", health)
+	}
+
+	env, ok := health.Environments["development"]
+
+	if !ok || env.Ready || env.Upstream == nil || env.Upstream.Reachable || env.Upstream.Serving != 0 || env.Upstream.Upstreams != 1 {
+		t.Fatalf("Expected an unreachable development environment, got %+v", env)
+	}
+
+	if env.Engine.State != "ok" || env.Stream != nil || env.Subscribers != 0 {
+		t.Errorf("Expected a working engine without stream or subscribers, got %+v", env)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/Iandenh/overleash/internal/version"
 	"github.com/Iandenh/overleash/overleash"
+	"github.com/Iandenh/overleash/unleashengine"
 )
 
 func constraintsOfStrategy(strategy overleash.Strategy, segments map[int][]overleash.Constraint) []overleash.Constraint {
@@ -121,6 +123,7 @@ func (list *featureList) generateUrl(key, value string) string {
 	urlValues.Set("q", list.searchTerm)
 	urlValues.Set("sort", list.sort)
 	urlValues.Set("filter", list.filter)
+	urlValues.Set("tag", list.tag)
 
 	urlValues.Set(key, value)
 
@@ -133,6 +136,7 @@ func (list *featureList) isSelected(key, value string) bool {
 	urlValues.Set("q", list.searchTerm)
 	urlValues.Set("sort", list.sort)
 	urlValues.Set("filter", list.filter)
+	urlValues.Set("tag", list.tag)
 
 	return urlValues.Get(key) == value
 }
@@ -152,6 +156,36 @@ func renderJiraLink(text string) string {
 	})
 }
 
+// upstreamHost returns the host of an upstream URL for display, or the URL
+// itself when it cannot be parsed.
+func upstreamHost(upstream string) string {
+	u, err := url.Parse(upstream)
+
+	if err != nil || u.Host == "" {
+		return upstream
+	}
+
+	return u.Host
+}
+
+// enabledEnvironments lists the environments a flag is enabled in according
+// to the admin API.
+func enabledEnvironments(metadata overleash.FeatureMetadata) string {
+	enabled := make([]string, 0, len(metadata.Environments))
+
+	for _, env := range metadata.Environments {
+		if env.Enabled {
+			enabled = append(enabled, env.Name)
+		}
+	}
+
+	if len(enabled) == 0 {
+		return "none"
+	}
+
+	return strings.Join(enabled, ", ")
+}
+
 func (c *Server) featureEnvironmentFromRequest(r *http.Request) *overleash.FeatureEnvironment {
 	if c.Overleash.Config.EnvFromToken == false {
 		return c.Overleash.ActiveFeatureEnvironment()
@@ -177,3 +211,96 @@ func (c *Server) featureEnvironmentFromRequest(r *http.Request) *overleash.Featu
 
 	return c.Overleash.ActiveFeatureEnvironment()
 }
+
+// requestState compiles the transient overrides of the X-Overleash-Override
+// header for env. It returns nil when the request carries none, or when request
+// overrides are disabled.
+func (c *Server) requestState(r *http.Request, env *overleash.FeatureEnvironment) (*overleash.RequestState, error) {
+	if !c.Overleash.Config.RequestOverrides {
+		return nil, nil
+	}
+
+	header := r.Header.Get(overleash.RequestOverrideHeader)
+
+	if header == "" {
+		return nil, nil
+	}
+
+	overrides, err := overleash.ParseRequestOverrides(header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+
+	return env.WithRequestOverrides(overrides)
+}
+
+// engineFromRequest returns the engine to evaluate the request with, and a
+// function that releases it once the response is written.
+func (c *Server) engineFromRequest(r *http.Request) (unleashengine.Engine, func(), error) {
+	env := c.featureEnvironmentFromRequest(r)
+
+	state, err := c.requestState(r, env)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if state == nil {
+		return env.Engine(), func() {}, nil
+	}
+
+	engine, err := state.Engine()
+
+	if err != nil {
+		state.Close()
+
+		return nil, nil, err
+	}
+
+	return engine, state.Close, nil
+}
+
+// matchesEtag reports whether the If-None-Match header of the request names etag.
+func matchesEtag(r *http.Request, etag string) bool {
+	ifNoneMatch := strings.Trim(strings.TrimPrefix(r.Header.Get("If-None-Match"), "W/"), "\"")
+
+	return ifNoneMatch != "" && ifNoneMatch == etag
+}
+
+// syncTime formats a sync timestamp for display, or "never" when it is unset.
+func syncTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return t.Format("15:04:05")
+}
+
+// shortRevision returns a revision without its quotes and weak prefix,
+// shortened to its first 12 characters.
+func shortRevision(revision string) string {
+	revision = strings.Trim(strings.TrimPrefix(revision, "W/"), `"`)
+
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+
+	return revision
+}
+
+// syncTitle describes the last attempt and, when failing, the last error of
+// a sync status.
+func syncTitle(status overleash.SyncStatus) string {
+	title := "Last attempt: " + syncTime(status.LastAttempt)
+
+	if status.Failing() {
+		title += "\nLast error: " + status.LastError
+	}
+
+	return title
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"net/http"
 
+	"github.com/Iandenh/overleash/overleash"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -23,11 +24,76 @@ var (
 		},
 		[]string{"path", "method"},
 	)
+
+	frontendEvaluationCache = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "frontend_evaluation_cache_total",
+			Help: "Number of frontend API evaluations, labeled by whether they were served from the cache.",
+		},
+		[]string{"result"},
+	)
+
+	streamDroppedEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "stream_dropped_events_total",
+			Help: "Number of streaming events not delivered because the subscriber could not keep up.",
+		},
+	)
+
+	streamForcedResyncs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stream_forced_resyncs_total",
+			Help: "Number of streaming subscribers forced to resync after dropping events, labeled by policy.",
+		},
+		[]string{"policy"},
+	)
 )
 
 func init() {
 	// Register metrics
-	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration)
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, frontendEvaluationCache, streamDroppedEvents, streamForcedResyncs)
+}
+
+var (
+	upstreamCircuitStateDesc = prometheus.NewDesc(
+		"upstream_circuit_state",
+		"State of the circuit breaker guarding the upstream, labeled by environment and state; 1 for the current state.",
+		[]string{"environment", "state"}, nil,
+	)
+
+	upstreamFailuresDesc = prometheus.NewDesc(
+		"upstream_consecutive_failures",
+		"Number of consecutive failed fetches from the upstream, labeled by environment.",
+		[]string{"environment"}, nil,
+	)
+)
+
+// upstreamCollector reports the circuit breaker of every environment when
+// scraped.
+type upstreamCollector struct {
+	o *overleash.OverleashContext
+}
+
+func (u *upstreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upstreamCircuitStateDesc
+	ch <- upstreamFailuresDesc
+}
+
+func (u *upstreamCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, env := range u.o.FeatureEnvironments() {
+		status := env.UpstreamStatus()
+
+		for _, state := range []overleash.CircuitState{overleash.CircuitClosed, overleash.CircuitOpen, overleash.CircuitHalfOpen} {
+			value := 0.0
+			if status.State == state {
+				value = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(upstreamCircuitStateDesc, prometheus.GaugeValue, value, env.Environment(), string(state))
+		}
+
+		ch <- prometheus.MustNewConstMetric(upstreamFailuresDesc, prometheus.GaugeValue, float64(status.Failures), env.Environment())
+	}
 }
 
 func instrumentHandler(next http.Handler) http.Handler {
@@ -40,3 +106,11 @@ func instrumentHandler(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func recordEvaluationCache(hit bool) {
+	if hit {
+		frontendEvaluationCache.WithLabelValues("hit").Inc()
+	} else {
+		frontendEvaluationCache.WithLabelValues("miss").Inc()
+	}
+}
@@ -19,9 +19,13 @@ func cacheControlMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func compress(next http.Handler, c func(http.Handler) http.Handler) http.Handler {
+func compress(next http.Handler, c func(http.Handler) http.Handler, basePath string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api/client/streaming") {
+		path := strings.TrimPrefix(r.URL.Path, basePath)
+
+		// Streams must not be buffered, and the feature file is served
+		// pre-compressed by its handler.
+		if strings.HasPrefix(path, "/api/client/streaming") || path == "/api/frontend/streaming" || path == "/dashboard/events" || path == "/api/client/features" {
 			next.ServeHTTP(w, r)
 			return
 		}
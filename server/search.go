@@ -17,6 +17,8 @@ type featureList struct {
 	searchTerm string
 	sort       string
 	filter     string
+	tag        string
+	tags       []string
 	url        string
 	totalFlags int
 }
@@ -40,11 +42,13 @@ func search(r *http.Request, o *overleash.OverleashContext) featureList {
 
 	sortField := strings.ToLower(query.Get("sort"))
 	filterOption := strings.ToLower(r.URL.Query().Get("filter"))
+	tag := query.Get("tag")
 
 	q := searchTerm(r)
 
 	flags := fuzzyFeatureFlags(q, o)
 	flags = filterFeaturesByOverrideStatus(flags, filterOption, o)
+	flags = filterFeaturesByTag(flags, tag, o)
 
 	sortFeatures(flags, sortField)
 
@@ -62,6 +66,10 @@ func search(r *http.Request, o *overleash.OverleashContext) featureList {
 		urlValues.Set("filter", filterOption)
 	}
 
+	if tag != "" {
+		urlValues.Set("tag", tag)
+	}
+
 	finalURL := o.Config.CleanBasePath() + "/"
 	encodedQuery := urlValues.Encode()
 	if encodedQuery != "" {
@@ -73,6 +81,8 @@ func search(r *http.Request, o *overleash.OverleashContext) featureList {
 		searchTerm: q,
 		sort:       sortField,
 		filter:     filterOption,
+		tag:        tag,
+		tags:       o.ActiveFeatureEnvironment().Tags(),
 		url:        finalURL,
 		totalFlags: o.ActiveFeatureEnvironment().FeatureFile().Features.Len(),
 	}
@@ -106,6 +116,15 @@ func fuzzyFeatureFlags(search string, o *overleash.OverleashContext) overleash.F
 		flags = append(flags, flag)
 	}
 
+	// Flags whose project, tags or owners match are found as well.
+	for _, flag := range o.ActiveFeatureEnvironment().FeatureFile().Features {
+		if metadata, ok := o.ActiveFeatureEnvironment().Metadata(flag.Name); ok && metadata.Matches(search) {
+			if !slices.ContainsFunc(flags, func(f overleash.Feature) bool { return f.Name == flag.Name }) {
+				flags = append(flags, flag)
+			}
+		}
+	}
+
 	return flags
 }
 
@@ -144,3 +163,19 @@ func filterFeaturesByOverrideStatus(flags overleash.FeatureFlags, filterOption s
 
 	return filteredFlags
 }
+
+func filterFeaturesByTag(flags overleash.FeatureFlags, tag string, o *overleash.OverleashContext) overleash.FeatureFlags {
+	if tag == "" {
+		return flags
+	}
+
+	filteredFlags := make(overleash.FeatureFlags, 0)
+
+	for _, flag := range flags {
+		if metadata, ok := o.ActiveFeatureEnvironment().Metadata(flag.Name); ok && metadata.HasTag(tag) {
+			filteredFlags = append(filteredFlags, flag)
+		}
+	}
+
+	return filteredFlags
+}
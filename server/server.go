@@ -8,15 +8,18 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"sync"
 	"time"
 
 	"github.com/CAFxX/httpcompression"
+	"github.com/Iandenh/overleash/config"
 	"github.com/Iandenh/overleash/overleash"
 	"github.com/a-h/templ"
 	"github.com/charmbracelet/log"
 	"github.com/medama-io/go-useragent"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 )
@@ -31,14 +34,30 @@ var ua = useragent.NewParser()
 const maxBodySize = 1 * 1024 * 1024 // 1 MiB
 
 type Server struct {
-	Overleash *overleash.OverleashContext
-	ctx       context.Context
+	Overleash       *overleash.OverleashContext
+	ctx             context.Context
+	defaultContexts map[string]config.DefaultContext
+	trustedProxies  []netip.Prefix
 }
 
-func New(config *overleash.OverleashContext, ctx context.Context) *Server {
+func New(o *overleash.OverleashContext, ctx context.Context) *Server {
+	defaultContexts, err := o.Config.DefaultContexts()
+
+	if err != nil {
+		log.Error(err)
+	}
+
+	trustedProxies, err := o.Config.TrustedProxyPrefixes()
+
+	if err != nil {
+		log.Error(err)
+	}
+
 	return &Server{
-		Overleash: config,
-		ctx:       ctx,
+		Overleash:       o,
+		ctx:             ctx,
+		defaultContexts: defaultContexts,
+		trustedProxies:  trustedProxies,
 	}
 }
 
@@ -66,6 +85,7 @@ func (c *Server) Start() {
 
 	if c.Overleash.Config.EnableFrontend {
 		c.registerFrontendApi(s)
+		c.registerFrontendStreaming(s)
 	}
 
 	if c.Overleash.Config.Streamer {
@@ -74,12 +94,29 @@ func (c *Server) Start() {
 
 	if !c.Overleash.Config.Headless {
 		c.registerDashboardApi(s)
+		c.registerDashboardEvents(s)
 	}
 
 	s.HandleFunc("GET /health", func(w http.ResponseWriter, request *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		status := map[string]string{"status": "ok"}
-		json.NewEncoder(w).Encode(status)
+		json.NewEncoder(w).Encode(c.health())
+	})
+
+	s.HandleFunc("GET /health/details", func(w http.ResponseWriter, request *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.detailedHealth(request.Context()))
+	})
+
+	s.HandleFunc("GET /ready", func(w http.ResponseWriter, request *http.Request) {
+		ready := c.ready()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if len(ready.Waiting) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(ready)
 	})
 
 	// 3. Create the Root Handler
@@ -90,7 +127,7 @@ func (c *Server) Start() {
 		stripped := http.StripPrefix(basePath, s)
 
 		rootHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/health" {
+			if r.URL.Path == "/health" || r.URL.Path == "/health/details" || r.URL.Path == "/ready" {
 				s.ServeHTTP(w, r)
 				return
 			}
@@ -113,7 +150,7 @@ func (c *Server) Start() {
 	handler := cors.AllowAll().Handler(rootHandler)
 	com, _ := httpcompression.DefaultAdapter()
 
-	handler = compress(handler, com)
+	handler = compress(handler, com, basePath)
 
 	if c.Overleash.Config.PrometheusMetrics {
 		handler = instrumentHandler(handler)
@@ -136,6 +173,10 @@ func (c *Server) Start() {
 
 	var metricsServer *http.Server
 	if c.Overleash.Config.PrometheusMetrics == true {
+		if err := prometheus.Register(&upstreamCollector{c.Overleash}); err != nil {
+			log.Errorf("Unable to register upstream metrics: %v", err)
+		}
+
 		mux := http.NewServeMux()
 		mux.Handle("/metrics", promhttp.Handler())
 
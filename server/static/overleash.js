@@ -397,8 +397,49 @@ const overleash = function() {
         htmx.trigger(remoteSelect, "remote");
     }
 
+    /**
+     * Keeps the dashboard in sync with changes made elsewhere, e.g. by a
+     * teammate in another dashboard or by an upstream sync.
+     */
+    const listen = () => {
+        const events = new EventSource("dashboard/events");
+
+        events.addEventListener("flag", event => {
+            const card = document.querySelector(`.flag[data-flag="${CSS.escape(event.data)}"]`);
+
+            if (card === null) {
+                htmx.trigger(searchBar, "search");
+                return;
+            }
+
+            const details = card.querySelector(".list.muted") !== null ? "?details=true" : "";
+
+            htmx.ajax("GET", "dashboard/feature/" + encodeURIComponent(event.data) + details, {
+                target: card,
+                swap: "innerHTML",
+            });
+        });
+
+        events.addEventListener("list", () => {
+            htmx.trigger(searchBar, "search");
+        });
+
+        events.addEventListener("refresh", () => {
+            htmx.ajax("GET", window.location.href, {
+                target: "body",
+                swap: "innerHTML",
+            });
+        });
+
+        events.addEventListener("sync", () => {
+            htmx.trigger("#last-sync", "sync");
+            htmx.trigger("#environment-sync", "sync");
+        });
+    };
+
     load();
     focus();
+    listen();
 }
 
 if (document.readyState !== 'loading') {
@@ -33,6 +33,14 @@ func NewUnleashEngine() *UnleashEngine {
 	return &UnleashEngine{ptr: ptr}
 }
 
+// Close releases the engine. It must not be used afterwards.
+func (e *UnleashEngine) Close() error {
+	C.free_engine(e.ptr)
+	e.ptr = nil
+
+	return nil
+}
+
 // TakeState replaces the engine's feature toggle state.
 //
 // A rejected payload leaves the previously loaded state in place, so ignoring